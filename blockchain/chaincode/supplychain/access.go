@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AccessRequest is a pending request from an organization to be granted access to supply chain data
+type AccessRequest struct {
+	DataID       string    `json:"dataId"`
+	RequestorOrg string    `json:"requestorOrg"` // Organization asking for access
+	OwnerOrg     string    `json:"ownerOrg"`     // Organization that owns the data and can grant access
+	RequestedAt  time.Time `json:"requestedAt"`
+}
+
+// RequestDataAccess records a pending request from the client's organization for access to supply chain data. When
+// hideInaccessibleData is set, a request for data that does not exist, or that the organization already requested,
+// succeeds without recording anything, so the response does not reveal whether data the client cannot read exists.
+func (s *SmartContract) RequestDataAccess(ctx contractapi.TransactionContextInterface, dataID string) error {
+	// Get the supply chain data; the client cannot read it yet, so access control is not checked here
+	supplyChainData, err := getSupplyChainData(ctx, dataID)
+	if hideInaccessibleData && errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// There is nothing to request if the client can already access the data
+	if canAccess(clientOrgID, supplyChainData) {
+		return fmt.Errorf("%w: client from organization %s already has access to the supply chain data %s", ErrConflict, clientOrgID, dataID)
+	}
+
+	// Check if there is already a pending request
+	requestKey := accessRequestKey(dataID, clientOrgID)
+	existingRequestJSON, err := ctx.GetStub().GetState(requestKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingRequestJSON != nil {
+		if hideInaccessibleData {
+			return nil
+		}
+		return fmt.Errorf("%w: organization %s already has a pending access request for the supply chain data %s", ErrAlreadyExists, clientOrgID, dataID)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the access request object
+	accessRequest := AccessRequest{
+		DataID:       dataID,
+		RequestorOrg: clientOrgID,
+		OwnerOrg:     supplyChainData.OrganizationID,
+		RequestedAt:  now,
+	}
+
+	// Convert to JSON
+	accessRequestJSON, err := json.Marshal(accessRequest)
+	if err != nil {
+		return err
+	}
+
+	// Put the request on the ledger
+	err = ctx.GetStub().PutState(requestKey, accessRequestJSON)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so the owner's listener can prompt for approval without polling for requests
+	return emitEvent(ctx, "AccessRequested", AccessRequestedEvent{
+		EventHeader:  EventHeader{EventVersion: accessRequestedEventVersion},
+		DataID:       dataID,
+		RequestorOrg: clientOrgID,
+		OwnerOrg:     supplyChainData.OrganizationID,
+		RequestedAt:  now,
+	})
+}
+
+// GrantDataAccess grants a pending access request by adding the requesting organization to the data's
+// AccessControl. Only the owning organization can grant access.
+func (s *SmartContract) GrantDataAccess(ctx contractapi.TransactionContextInterface, dataID, requestorOrg string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, dataID)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// Check that there is a pending request to grant
+	requestKey := accessRequestKey(dataID, requestorOrg)
+	accessRequestJSON, err := ctx.GetStub().GetState(requestKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if accessRequestJSON == nil {
+		return fmt.Errorf("%w: there is no pending access request from organization %s for the supply chain data %s", ErrNotFound, requestorOrg, dataID)
+	}
+
+	// Grant access
+	if !canAccess(requestorOrg, supplyChainData) {
+		supplyChainData.AccessControl = append(supplyChainData.AccessControl, requestorOrg)
+	}
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// The request has been handled
+	err = ctx.GetStub().DelState(requestKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete from world state: %v", err)
+	}
+
+	err = writeAuditEntry(ctx, "GrantDataAccess", dataID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so the requesting organization knows it can read the data
+	return emitEvent(ctx, "AccessGranted", struct {
+		DataID       string `json:"dataId"`
+		RequestorOrg string `json:"requestorOrg"`
+	}{dataID, requestorOrg})
+}
+
+// RevokeDataAccess proposes removing an organization from the data's AccessControl. Only the owning organization can
+// revoke access, and the organization required for the data's type cannot be removed. The proposal counts as the
+// owner's approval; access is revoked once revocationQuorum listed organizations approve with ApproveRevocation, so
+// partners are not de-provisioned unilaterally.
+func (s *SmartContract) RevokeDataAccess(ctx contractapi.TransactionContextInterface, dataID, orgToRevoke string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, dataID)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if !containsOrg(supplyChainData.AccessControl, orgToRevoke) {
+		return fmt.Errorf("%w: organization %s does not have access to the supply chain data %s", ErrInvalidArgument, orgToRevoke, dataID)
+	}
+
+	// Mandatory access always wins over the owner's choices
+	requiredOrg, err := getMandatoryAccessOrg(ctx, supplyChainData.DataType)
+	if err != nil {
+		return err
+	}
+	if sameOrg(orgToRevoke, requiredOrg) {
+		return fmt.Errorf("%w: organization %s must always have access to %s data and cannot be revoked", ErrConflict, orgToRevoke, supplyChainData.DataType)
+	}
+
+	// Check if there is already a pending revocation
+	existingRevocation, err := getRevocationApproval(ctx, dataID, orgToRevoke)
+	if err != nil {
+		return err
+	}
+	if existingRevocation != nil {
+		return fmt.Errorf("%w: the revocation of organization %s for the supply chain data %s is already pending", ErrAlreadyExists, orgToRevoke, dataID)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Record the proposal along with the owner's approval
+	revocation := &RevocationApproval{
+		DataID:     dataID,
+		RevokedOrg: orgToRevoke,
+		ProposedBy: supplyChainData.OrganizationID,
+		Approvals:  []string{supplyChainData.OrganizationID},
+		ProposedAt: now,
+	}
+
+	return applyRevocationApprovals(ctx, supplyChainData, revocation, "RevokeDataAccess", "AccessRevocationProposed")
+}
+
+// Helper function to get the ledger key of an access request, honoring caseInsensitiveOrgIDs
+func accessRequestKey(dataID, requestorOrg string) string {
+	if caseInsensitiveOrgIDs {
+		requestorOrg = strings.ToUpper(requestorOrg)
+	}
+	return fmt.Sprintf("%s%s_%s", accessRequestKeyPrefix, dataID, requestorOrg)
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestRequestDataAccessDoesNotRevealHiddenData(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP")
+
+	// Requesting access to missing data and repeating a request only fail when existence may be revealed
+	missingErr := s.RequestDataAccess(stub.as("Org2MSP"), "missing")
+	mustSucceed(t, s.RequestDataAccess(stub.as("Org2MSP"), "data1"))
+	repeatErr := s.RequestDataAccess(stub.as("Org2MSP"), "data1")
+	if hideInaccessibleData {
+		mustSucceed(t, missingErr)
+		mustSucceed(t, repeatErr)
+	} else {
+		mustFailWith(t, missingErr, ErrNotFound)
+		mustFailWith(t, repeatErr, ErrAlreadyExists)
+	}
+
+	// Data the client can already read reveals nothing new
+	mustSucceed(t, s.GrantDataAccess(stub.as("Org1MSP"), "data1", "Org2MSP"))
+	mustFailWith(t, s.RequestDataAccess(stub.as("Org2MSP"), "data1"), ErrConflict)
+}
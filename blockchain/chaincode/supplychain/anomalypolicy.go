@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// defaultAnomalyThreshold is the score an anomaly must exceed to be flagged when no threshold is set for its data type
+const defaultAnomalyThreshold = 0.5
+
+// AnomalyPolicy sets how sensitive anomaly flagging is for a type of data
+type AnomalyPolicy struct {
+	DataType         string    `json:"dataType"`
+	AnomalyThreshold float64   `json:"anomalyThreshold"` // Score an anomaly must exceed to be flagged
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// SetAnomalyThreshold sets the score anomalies of the given data type must exceed to be flagged by
+// UpdateAnomalyStatus. Only the admin organization can set thresholds.
+func (s *SmartContract) SetAnomalyThreshold(ctx contractapi.TransactionContextInterface, dataType string, anomalyThreshold float64) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is the admin organization
+	if !sameOrg(clientOrgID, adminMSPID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to set anomaly thresholds", ErrUnauthorized, clientOrgID)
+	}
+
+	err = ValidateDataType(dataType)
+	if err != nil {
+		return err
+	}
+	if anomalyThreshold < 0 || anomalyThreshold > 1 {
+		return fmt.Errorf("%w: anomaly threshold must be between 0.0 and 1.0, got %g", ErrInvalidArgument, anomalyThreshold)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the anomaly policy object
+	anomalyPolicy := AnomalyPolicy{
+		DataType:         dataType,
+		AnomalyThreshold: anomalyThreshold,
+		UpdatedAt:        now,
+	}
+
+	// Convert to JSON
+	anomalyPolicyJSON, err := json.Marshal(anomalyPolicy)
+	if err != nil {
+		return err
+	}
+
+	// Put the anomaly policy on the ledger
+	return ctx.GetStub().PutState(anomalyPolicyKeyPrefix+dataType, anomalyPolicyJSON)
+}
+
+// GetAnomalyThreshold returns the score anomalies of the given data type must exceed to be flagged
+func (s *SmartContract) GetAnomalyThreshold(ctx contractapi.TransactionContextInterface, dataType string) (float64, error) {
+	return getAnomalyThreshold(ctx, dataType)
+}
+
+// getAnomalyThreshold returns the threshold set for a data type, or defaultAnomalyThreshold if none is set
+func getAnomalyThreshold(ctx contractapi.TransactionContextInterface, dataType string) (float64, error) {
+	anomalyPolicyJSON, err := ctx.GetStub().GetState(anomalyPolicyKeyPrefix + dataType)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if anomalyPolicyJSON == nil {
+		return defaultAnomalyThreshold, nil
+	}
+
+	var anomalyPolicy AnomalyPolicy
+	err = json.Unmarshal(anomalyPolicyJSON, &anomalyPolicy)
+	if err != nil {
+		return 0, err
+	}
+
+	return anomalyPolicy.AnomalyThreshold, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// UpdateAttributeAccessControl replaces the client attributes that grant read access to a supply chain data point
+// regardless of the client's organization. Each attribute is written as name=value, e.g. "role=auditor", and matches
+// clients whose certificate carries that attribute. Only the owning organization can update it.
+func (s *SmartContract) UpdateAttributeAccessControl(ctx contractapi.TransactionContextInterface, id string, attributes []string) error {
+	normalized := []string{}
+	for _, attribute := range attributes {
+		name, value, err := parseAttribute(attribute)
+		if err != nil {
+			return err
+		}
+		attribute = name + "=" + value
+		if !contains(normalized, attribute) {
+			normalized = append(normalized, attribute)
+		}
+	}
+
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// Update the attribute access control list
+	supplyChainData.AttributeAccessControl = normalized
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "UpdateAttributeAccessControl", id)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so partner organizations can refresh their cached permissions
+	return emitEvent(ctx, "AttributeAccessControlUpdated", struct {
+		ID                     string   `json:"id"`
+		AttributeAccessControl []string `json:"attributeAccessControl"`
+	}{id, normalized})
+}
+
+// hasAttributeAccess returns true if the client's certificate carries one of the attributes that grant access to
+// the supply chain data
+func hasAttributeAccess(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) (bool, error) {
+	for _, attribute := range supplyChainData.AttributeAccessControl {
+		name, value, err := parseAttribute(attribute)
+		if err != nil {
+			continue // Skip malformed entries
+		}
+
+		clientValue, found, err := ctx.GetClientIdentity().GetAttributeValue(name)
+		if err != nil {
+			return false, fmt.Errorf("failed to get client attribute %s: %v", name, err)
+		}
+		if found && clientValue == value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseAttribute splits an attribute access entry written as name=value
+func parseAttribute(attribute string) (string, string, error) {
+	name, value, ok := strings.Cut(attribute, "=")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || value == "" {
+		return "", "", fmt.Errorf("%w: attribute %q must be written as name=value", ErrInvalidArgument, attribute)
+	}
+	return name, value, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AuditEntry records a change made to supply chain data. Audit entries are written alongside the change and no
+// function deletes or updates them.
+type AuditEntry struct {
+	TxID        string    `json:"txId"`
+	Operation   string    `json:"operation"` // Name of the contract function that made the change
+	TargetID    string    `json:"targetId"`  // ID of the supply chain data that was changed
+	CallerMSPID string    `json:"callerMspId"`
+	Timestamp   time.Time `json:"timestamp"` // Timestamp of the transaction
+}
+
+// QueryAuditTrail returns the audit entries of a supply chain data point, oldest first. Organizations that can
+// access the data can see its trail; the trail of deleted data can only be seen by the admin organization.
+func (s *SmartContract) QueryAuditTrail(ctx contractapi.TransactionContextInterface, targetID string) ([]*AuditEntry, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to see the trail
+	supplyChainData, err := getSupplyChainData(ctx, targetID)
+	switch {
+	case err == nil:
+		if !canAccess(clientOrgID, supplyChainData) {
+			return nil, readDeniedError(clientOrgID, targetID)
+		}
+	case sameOrg(clientOrgID, adminMSPID):
+		// The admin organization can audit deleted data
+	default:
+		return nil, err
+	}
+
+	// Query the ledger for the audit entries of the target
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"_id":      map[string]string{"$regex": "^" + auditKeyPrefix},
+			"targetId": targetID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Collect the results
+	auditEntries := []*AuditEntry{}
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip anything that is not an audit entry
+		if !strings.HasPrefix(queryResult.Key, auditKeyPrefix) {
+			continue
+		}
+
+		var auditEntry AuditEntry
+		err = json.Unmarshal(queryResult.Value, &auditEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		auditEntries = append(auditEntries, &auditEntry)
+	}
+
+	sort.SliceStable(auditEntries, func(i, j int) bool {
+		return auditEntries[i].Timestamp.Before(auditEntries[j].Timestamp)
+	})
+
+	return auditEntries, nil
+}
+
+// writeAuditEntry records that the client made a change to supply chain data in the current transaction. The key
+// includes the target ID so a transaction that changes several data points records each of them.
+func writeAuditEntry(ctx contractapi.TransactionContextInterface, operation, targetID string) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the audit entry object
+	txID := ctx.GetStub().GetTxID()
+	auditEntry := AuditEntry{
+		TxID:        txID,
+		Operation:   operation,
+		TargetID:    targetID,
+		CallerMSPID: clientOrgID,
+		Timestamp:   txTimestamp,
+	}
+
+	// Convert to JSON
+	auditEntryJSON, err := json.Marshal(auditEntry)
+	if err != nil {
+		return err
+	}
+
+	// Put the audit entry on the ledger
+	return ctx.GetStub().PutState(fmt.Sprintf("%s%s_%s", auditKeyPrefix, txID, targetID), auditEntryJSON)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventCoalescing records that an organization wants its bulk operations summarized in a single event
+type EventCoalescing struct {
+	OrganizationID string    `json:"organizationId"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// SetEventCoalescing enables or disables event coalescing for an organization. While it is enabled,
+// UpdateAnomalyStatusBatch submitted by the organization emits one AnomalyBatchUpdated event listing every updated
+// data point and the detected anomalies per severity, instead of AnomalyDetected events. Only the organization itself
+// can change its setting.
+func (s *SmartContract) SetEventCoalescing(ctx contractapi.TransactionContextInterface, organizationID string, enabled bool) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if the client is allowed to change the setting for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to set event coalescing for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	if !enabled {
+		err = ctx.GetStub().DelState(eventCoalescingKey(clientOrgID))
+		if err != nil {
+			return fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		return nil
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Convert to JSON
+	eventCoalescingJSON, err := json.Marshal(EventCoalescing{
+		OrganizationID: clientOrgID,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Put the setting on the ledger
+	return ctx.GetStub().PutState(eventCoalescingKey(clientOrgID), eventCoalescingJSON)
+}
+
+// isEventCoalescingEnabled returns true when the organization enabled event coalescing
+func isEventCoalescingEnabled(ctx contractapi.TransactionContextInterface, organizationID string) (bool, error) {
+	eventCoalescingJSON, err := ctx.GetStub().GetState(eventCoalescingKey(organizationID))
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return eventCoalescingJSON != nil, nil
+}
+
+// eventCoalescingKey returns the ledger key of an organization's event coalescing setting, honoring
+// caseInsensitiveOrgIDs
+func eventCoalescingKey(organizationID string) string {
+	if caseInsensitiveOrgIDs {
+		organizationID = strings.ToUpper(organizationID)
+	}
+	return eventCoalescingKeyPrefix + organizationID
+}
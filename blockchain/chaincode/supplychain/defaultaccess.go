@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DefaultAccessList is the access control list an organization's new supply chain data gets when none is given
+type DefaultAccessList struct {
+	OrganizationID string    `json:"organizationId"`
+	Orgs           []string  `json:"orgs"` // Organizations to share new data with
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// SetDefaultAccessList sets the organizations the organization's new supply chain data is shared with when it is
+// created with an empty access control list. An empty orgs list removes the default. Only the organization itself
+// can set its default access list.
+func (s *SmartContract) SetDefaultAccessList(ctx contractapi.TransactionContextInterface, organizationID string, orgs []string) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if the client is allowed to set the default access list for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to set the default access list for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	for _, org := range orgs {
+		if strings.TrimSpace(org) == "" {
+			return fmt.Errorf("%w: default access list must not contain an empty organization", ErrInvalidArgument)
+		}
+	}
+
+	orgs = normalizeOrgIDs(orgs)
+	if len(orgs) == 0 {
+		err = ctx.GetStub().DelState(defaultAccessListKey(clientOrgID))
+		if err != nil {
+			return fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		return nil
+	}
+
+	// Catch organizations that are not part of the network
+	err = s.checkRegisteredOrgs(ctx, orgs)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the default access list object
+	defaultAccessList := DefaultAccessList{
+		OrganizationID: clientOrgID,
+		Orgs:           orgs,
+		UpdatedAt:      now,
+	}
+
+	// Convert to JSON
+	defaultAccessListJSON, err := json.Marshal(defaultAccessList)
+	if err != nil {
+		return err
+	}
+
+	// Put the default access list on the ledger
+	return ctx.GetStub().PutState(defaultAccessListKey(clientOrgID), defaultAccessListJSON)
+}
+
+// getDefaultAccessList returns the organizations an organization's new data is shared with by default, or nil if
+// it has not set a default access list
+func getDefaultAccessList(ctx contractapi.TransactionContextInterface, organizationID string) ([]string, error) {
+	defaultAccessListJSON, err := ctx.GetStub().GetState(defaultAccessListKey(organizationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if defaultAccessListJSON == nil {
+		return nil, nil
+	}
+
+	var defaultAccessList DefaultAccessList
+	err = json.Unmarshal(defaultAccessListJSON, &defaultAccessList)
+	if err != nil {
+		return nil, err
+	}
+
+	return defaultAccessList.Orgs, nil
+}
+
+// defaultAccessListKey returns the ledger key of an organization's default access list, honoring
+// caseInsensitiveOrgIDs
+func defaultAccessListKey(organizationID string) string {
+	if caseInsensitiveOrgIDs {
+		organizationID = strings.ToUpper(organizationID)
+	}
+	return defaultAccessKeyPrefix + organizationID
+}
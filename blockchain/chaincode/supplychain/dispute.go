@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MarkDisputed flags a supply chain data point as disputed when an organization disagrees about its validity.
+// Any organization that can access the data may dispute it. While disputed, every transaction that writes the data
+// is rejected with a CONFLICT error, so neither party can silently overwrite it or move it out of reach, until the
+// owner calls ResolveDispute: its payload, anomaly status and pending resolution, access control list and pending
+// revocations, redactions, tags and parents cannot be changed, and it cannot be archived, deleted or transferred.
+// EnforceRetention skips disputed data, and MigrateRecords still upgrades its layout, which changes no content. The data's endorsement
+// policy still requires a peer of the owner to endorse the dispute itself.
+func (s *SmartContract) MarkDisputed(ctx contractapi.TransactionContextInterface, id, reason string) error {
+	// Validate the reason before touching the ledger
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("%w: dispute reason must not be empty", ErrInvalidArgument)
+	}
+	if !utf8.ValidString(reason) {
+		return fmt.Errorf("%w: dispute reason must be valid UTF-8", ErrInvalidArgument)
+	}
+	if length := utf8.RuneCountInString(reason); length > maxExplanationLength {
+		return fmt.Errorf("%w: dispute reason is %d characters long, the maximum is %d", ErrInvalidArgument, length, maxExplanationLength)
+	}
+
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, id, false)
+	if err != nil {
+		return err
+	}
+
+	if supplyChainData.Disputed {
+		return fmt.Errorf("%w: the supply chain data %s is already disputed", ErrConflict, id)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Mark the data as disputed
+	supplyChainData.Disputed = true
+	supplyChainData.DisputeReason = reason
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "MarkDisputed", id)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so the owner can look into the dispute
+	return emitEvent(ctx, "DataDisputed", DataDisputedEvent{
+		EventHeader:    EventHeader{EventVersion: dataDisputedEventVersion},
+		ID:             id,
+		OrganizationID: supplyChainData.OrganizationID,
+		DisputedBy:     clientOrgID,
+		Reason:         reason,
+	})
+}
+
+// ResolveDispute clears the dispute of a supply chain data point. Only the owning organization can resolve it.
+func (s *SmartContract) ResolveDispute(ctx contractapi.TransactionContextInterface, id string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !supplyChainData.Disputed {
+		return fmt.Errorf("%w: the supply chain data %s is not disputed", ErrConflict, id)
+	}
+
+	// Clear the dispute
+	supplyChainData.Disputed = false
+	supplyChainData.DisputeReason = ""
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "ResolveDispute", id)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so the disputing organization knows the data can change again
+	return emitEvent(ctx, "DisputeResolved", DisputeResolvedEvent{
+		EventHeader:    EventHeader{EventVersion: disputeResolvedEventVersion},
+		ID:             id,
+		OrganizationID: supplyChainData.OrganizationID,
+	})
+}
+
+// checkNotDisputed returns a CONFLICT error if the supply chain data is disputed and must not be changed
+func checkNotDisputed(supplyChainData *SupplyChainData) error {
+	if supplyChainData.Disputed {
+		return fmt.Errorf("%w: the supply chain data %s is disputed (%s) and cannot be changed until the dispute is resolved", ErrConflict, supplyChainData.ID, supplyChainData.DisputeReason)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import "testing"
+
+// setUpDisputedData creates data1 owned by Org1MSP and shared with Org2MSP and Org4MSP, with a detected anomaly, a
+// pending resolution, a pending revocation of Org4MSP and a pending access request from Org3MSP, then disputes it
+func setUpDisputedData(t *testing.T) *testStub {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "root1", "Org1MSP")
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP", "Org4MSP")
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	mustSucceed(t, s.UpdateAnomalyStatus(stub.as("Org1MSP"), "data1", true, 0.95, "temperature excursion", "", data.Version))
+	mustSucceed(t, s.ProposeAnomalyResolution(stub.as("Org2MSP"), "data1"))
+	mustSucceed(t, s.RevokeDataAccess(stub.as("Org1MSP"), "data1", "Org4MSP"))
+	mustSucceed(t, s.RequestDataAccess(stub.as("Org3MSP"), "data1"))
+
+	mustSucceed(t, s.MarkDisputed(stub.as("Org2MSP"), "data1", "wrong quantity"))
+	return stub
+}
+
+func TestDisputedDataCannotBeChanged(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDisputedData(t)
+
+	mustFailWith(t, s.DeleteSupplyChainData(stub.as("Org1MSP"), "data1"), ErrConflict)
+	mustFailWith(t, s.ArchiveSupplyChainData(stub.as("Org1MSP"), "data1"), ErrConflict)
+	mustFailWith(t, s.TransferOwnership(stub.as("Org1MSP"), "data1", "Org3MSP", ""), ErrConflict)
+	mustFailWith(t, s.UpdateAccessControl(stub.as("Org1MSP"), "data1", []string{"Org2MSP", "Org3MSP", "Org4MSP"}), ErrConflict)
+	mustFailWith(t, s.UpdateAttributeAccessControl(stub.as("Org1MSP"), "data1", []string{"role=auditor"}), ErrConflict)
+	mustFailWith(t, s.UpdateParentIDs(stub.as("Org1MSP"), "data1", []string{"root1"}), ErrConflict)
+	mustFailWith(t, s.SetRedactedFields(stub.as("Org1MSP"), "data1", []string{"explanation"}), ErrConflict)
+	mustFailWith(t, s.AddTag(stub.as("Org1MSP"), "data1", "cold-chain"), ErrConflict)
+	_, err := s.ReassignDataType(stub.as("Org1MSP"), DataTypeShipment, DataTypeInventory, "Org1MSP")
+	mustFailWith(t, err, ErrConflict)
+
+	// Once the dispute is resolved the owner can change the data again
+	mustSucceed(t, s.ResolveDispute(stub.as("Org1MSP"), "data1"))
+	mustSucceed(t, s.UpdateAccessControl(stub.as("Org1MSP"), "data1", []string{"Org2MSP", "Org3MSP", "Org4MSP"}))
+	mustSucceed(t, s.TransferOwnership(stub.as("Org1MSP"), "data1", "Org3MSP", ""))
+}
+
+func TestDisputedDataAccessCannotBeChanged(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDisputedData(t)
+
+	mustFailWith(t, s.GrantDataAccess(stub.as("Org1MSP"), "data1", "Org3MSP"), ErrConflict)
+	mustFailWith(t, s.RevokeDataAccess(stub.as("Org1MSP"), "data1", "Org2MSP"), ErrConflict)
+	mustFailWith(t, s.ApproveRevocation(stub.as("Org2MSP"), "data1", "Org4MSP"), ErrConflict)
+
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if !containsOrg(data.AccessControl, "Org4MSP") || containsOrg(data.AccessControl, "Org3MSP") {
+		t.Fatalf("the access control list changed during the dispute: %q", data.AccessControl)
+	}
+}
+
+func TestDisputedDataAnomalyCannotBeResolved(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDisputedData(t)
+
+	// The quorum would be reached by this approval
+	mustFailWith(t, s.ApproveAnomalyResolution(stub.as("Org4MSP"), "data1"), ErrConflict)
+	mustFailWith(t, s.ClearAnomalies(stub.as("Org1MSP"), []string{"data1"}), ErrConflict)
+	if !anomalyDetected(t, stub) {
+		t.Fatal("the anomaly of disputed data was cleared")
+	}
+
+	mustSucceed(t, s.ResolveDispute(stub.as("Org1MSP"), "data1"))
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org4MSP"), "data1"))
+	if anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was not cleared once the dispute was resolved")
+	}
+}
+
+func TestDisputedDataCannotBeProposedForResolution(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	mustSucceed(t, s.UpdateAnomalyStatus(stub.as("Org1MSP"), "data1", true, 0.95, "temperature excursion", "", data.Version))
+	mustSucceed(t, s.MarkDisputed(stub.as("Org2MSP"), "data1", "wrong quantity"))
+
+	mustFailWith(t, s.ProposeAnomalyResolution(stub.as("Org2MSP"), "data1"), ErrConflict)
+}
+
+func TestEnforceRetentionSkipsDisputedData(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+	createTestData(t, stub, "data2", "Org1MSP")
+	mustSucceed(t, s.MarkDisputed(stub.as("Org2MSP"), "data1", "wrong quantity"))
+	mustSucceed(t, s.SetRetentionPolicy(stub.as("Org1MSP"), "Org1MSP", DataTypeShipment, 1))
+
+	// Move the clock past the retention period
+	stub.txCount += 2 * 24 * 60 * 60
+	archived, err := s.EnforceRetention(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if archived != 1 {
+		t.Fatalf("expected only the undisputed data to be archived, got %d", archived)
+	}
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if data.Archived {
+		t.Fatal("disputed data was archived")
+	}
+}
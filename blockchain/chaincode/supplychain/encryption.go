@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EncryptionInfo describes how the payload of a supply chain data point is encrypted, without the payload itself
+type EncryptionInfo struct {
+	ID               string    `json:"id"`
+	EncryptionScheme string    `json:"encryptionScheme"` // Empty for schema version 1 records that were never migrated
+	SchemaVersion    int       `json:"schemaVersion"`
+	KeyRotations     int       `json:"keyRotations"` // Times the payload was re-encrypted with RotateEncryptionKey
+	RotatedAt        time.Time `json:"rotatedAt"`    // Zero if the key was never rotated
+	Chunked          bool      `json:"chunked"`
+	Compressed       bool      `json:"compressed"`
+}
+
+// GetEncryptionInfo returns the encryption metadata of a supply chain data point, so key management tooling can plan
+// rotations without reading the ciphertext. It is subject to the same access control as ReadSupplyChainData.
+func (s *SmartContract) GetEncryptionInfo(ctx contractapi.TransactionContextInterface, id string) (*EncryptionInfo, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptionInfo{
+		ID:               supplyChainData.ID,
+		EncryptionScheme: supplyChainData.EncryptionScheme,
+		SchemaVersion:    supplyChainData.SchemaVersion,
+		KeyRotations:     supplyChainData.KeyRotations,
+		RotatedAt:        supplyChainData.RotatedAt,
+		Chunked:          supplyChainData.Chunked,
+		Compressed:       supplyChainData.Compressed,
+	}, nil
+}
+
+// RotateEncryptionKey replaces the payload of a supply chain data point with the same data re-encrypted under a new
+// key, and records the scheme used and how the payload is laid out. DataHash is a hash of the original data, so it
+// is kept. Only the owning organization can rotate the key, and not while the data is disputed.
+func (s *SmartContract) RotateEncryptionKey(ctx contractapi.TransactionContextInterface, id, encryptedData, encryptionScheme string, chunked, compressed bool) error {
+	if encryptedData == "" {
+		return fmt.Errorf("%w: encrypted data must not be empty", ErrInvalidArgument)
+	}
+	if encryptionScheme != EncryptionSchemeFernet && encryptionScheme != EncryptionSchemeNone {
+		return fmt.Errorf("%w: unknown encryption scheme %q", ErrInvalidArgument, encryptionScheme)
+	}
+
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Replace the payload and record the rotation
+	oldScheme := supplyChainData.EncryptionScheme
+	supplyChainData.EncryptedData = encryptedData
+	supplyChainData.EncryptionScheme = encryptionScheme
+	supplyChainData.Chunked = chunked
+	supplyChainData.Compressed = compressed
+	supplyChainData.KeyRotations++
+	supplyChainData.RotatedAt = now
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "RotateEncryptionKey", id)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "EncryptionKeyRotated", EncryptionKeyRotatedEvent{
+		EventHeader:    EventHeader{EventVersion: encryptionKeyRotatedEventVersion},
+		ID:             id,
+		OrganizationID: supplyChainData.OrganizationID,
+		OldScheme:      oldScheme,
+		NewScheme:      encryptionScheme,
+		KeyRotations:   supplyChainData.KeyRotations,
+		RotatedAt:      now,
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEncryptionInfo(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+
+	info, err := s.GetEncryptionInfo(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+	if info.EncryptionScheme != EncryptionSchemeFernet || info.KeyRotations != 0 || !info.RotatedAt.IsZero() || info.Chunked || info.Compressed {
+		t.Fatalf("unexpected encryption info of new data: %+v", info)
+	}
+
+	// Only the owner can rotate the key
+	mustFailWith(t, s.RotateEncryptionKey(stub.as("Org2MSP"), "data1", "rotated-payload", EncryptionSchemeFernet, true, true), ErrUnauthorized)
+	mustFailWith(t, s.RotateEncryptionKey(stub.as("Org1MSP"), "data1", "rotated-payload", "rot13", false, false), ErrInvalidArgument)
+
+	mustSucceed(t, s.RotateEncryptionKey(stub.as("Org1MSP"), "data1", "rotated-payload", EncryptionSchemeFernet, true, true))
+	info, err = s.GetEncryptionInfo(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+	if info.KeyRotations != 1 || !info.RotatedAt.Equal(testEpoch.Add(5*time.Second)) || !info.Chunked || !info.Compressed {
+		t.Fatalf("rotation not recorded: %+v", info)
+	}
+
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if data.EncryptedData != "rotated-payload" || data.DataHash != testDataHash("payload-data1") {
+		t.Fatalf("rotation should replace the payload and keep the data hash: %+v", data)
+	}
+
+	// Organizations without access learn nothing about the encryption either
+	_, err = s.GetEncryptionInfo(stub.as("Org3MSP"), "data1")
+	if err == nil {
+		t.Fatal("expected an error for an organization without access")
+	}
+}
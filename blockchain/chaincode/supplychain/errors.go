@@ -0,0 +1,15 @@
+package main
+
+import "errors"
+
+// Error codes returned by the chaincode. Every error a client should be able to act on wraps one of these, and the
+// code leads the message (e.g. "NOT_FOUND: the supply chain data x does not exist"), so Go callers can use errors.Is
+// while SDK clients that only see the message string can match on its prefix.
+var (
+	ErrNotFound        = errors.New("NOT_FOUND")
+	ErrUnauthorized    = errors.New("UNAUTHORIZED")
+	ErrAlreadyExists   = errors.New("ALREADY_EXISTS")
+	ErrInvalidArgument = errors.New("INVALID_ARGUMENT")
+	ErrConflict        = errors.New("CONFLICT")         // The data is not in a state that allows the operation
+	ErrResultTooLarge  = errors.New("RESULT_TOO_LARGE") // The result does not fit in one response; use a paginated function
+)
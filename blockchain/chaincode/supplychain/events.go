@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Current versions of the event payloads. Increment a version whenever its payload changes in a way subscribers
+// need to handle.
+const (
+	anomalyDetectedEventVersion      = 1
+	dataReclassifiedEventVersion     = 1
+	accessRequestedEventVersion      = 1
+	dataDisputedEventVersion         = 1
+	disputeResolvedEventVersion      = 1
+	encryptionKeyRotatedEventVersion = 1
+	anomalyBatchUpdatedEventVersion  = 1
+)
+
+// EventHeader carries the schema version of an event payload. Payloads embed it, so eventVersion appears alongside
+// their own fields, and subscribers can handle every payload version during rollouts. Payloads without an
+// eventVersion predate versioning.
+type EventHeader struct {
+	EventVersion int `json:"eventVersion"`
+}
+
+// AnomalyDetectedEvent is the payload of the AnomalyDetected event emitted by UpdateAnomalyStatus
+type AnomalyDetectedEvent struct {
+	EventHeader
+	ID              string          `json:"id"`
+	OrganizationID  string          `json:"organizationId"`
+	DataType        string          `json:"dataType"`
+	AnomalyScore    float64         `json:"anomalyScore"`
+	Severity        string          `json:"severity"`
+	Explanation     string          `json:"explanation"`
+	AnomalyMetadata json.RawMessage `json:"anomalyMetadata,omitempty"` // Structured model output, if any
+}
+
+// DataReclassifiedEvent is the payload of the DataReclassified event emitted by ReassignDataType
+type DataReclassifiedEvent struct {
+	EventHeader
+	OrganizationID string   `json:"organizationId"`
+	FromType       string   `json:"fromType"`
+	ToType         string   `json:"toType"`
+	IDs            []string `json:"ids"` // Supply chain data that was reclassified
+}
+
+// AccessRequestedEvent is the payload of the AccessRequested event emitted by RequestDataAccess
+type AccessRequestedEvent struct {
+	EventHeader
+	DataID       string    `json:"dataId"`
+	RequestorOrg string    `json:"requestorOrg"` // Organization asking for access
+	OwnerOrg     string    `json:"ownerOrg"`     // Organization that can grant the request with GrantDataAccess
+	RequestedAt  time.Time `json:"requestedAt"`  // Timestamp of the requesting transaction
+}
+
+// DataDisputedEvent is the payload of the DataDisputed event emitted by MarkDisputed
+type DataDisputedEvent struct {
+	EventHeader
+	ID             string `json:"id"`
+	OrganizationID string `json:"organizationId"` // Owner that can resolve the dispute
+	DisputedBy     string `json:"disputedBy"`
+	Reason         string `json:"reason"`
+}
+
+// DisputeResolvedEvent is the payload of the DisputeResolved event emitted by ResolveDispute
+type DisputeResolvedEvent struct {
+	EventHeader
+	ID             string `json:"id"`
+	OrganizationID string `json:"organizationId"`
+}
+
+// EncryptionKeyRotatedEvent is the payload of the EncryptionKeyRotated event emitted by RotateEncryptionKey
+type EncryptionKeyRotatedEvent struct {
+	EventHeader
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"organizationId"`
+	OldScheme      string    `json:"oldScheme"`
+	NewScheme      string    `json:"newScheme"`
+	KeyRotations   int       `json:"keyRotations"` // Rotations of the data's key so far, including this one
+	RotatedAt      time.Time `json:"rotatedAt"`
+}
+
+// AnomalyBatchUpdatedEvent is the payload of the AnomalyBatchUpdated event emitted by UpdateAnomalyStatusBatch in
+// place of individual AnomalyDetected events when the organization enabled coalescing with SetEventCoalescing
+type AnomalyBatchUpdatedEvent struct {
+	EventHeader
+	OrganizationID string         `json:"organizationId"` // Organization that submitted the batch
+	IDs            []string       `json:"ids"`            // Supply chain data that was updated
+	SeverityCounts map[string]int `json:"severityCounts"` // Detected anomalies per severity
+	Failed         int            `json:"failed"`         // Updates that could not be applied
+}
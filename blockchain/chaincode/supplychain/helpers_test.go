@@ -0,0 +1,444 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testEpoch is the timestamp of the first mocked transaction; each later transaction is one second after the last
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// testStub is a MockStub that also keeps the history of every key and answers rich queries. Rich queries evaluate
+// the subset of CouchDB selectors the chaincode uses, with duplicate keys resolved the way CouchDB does, and return
+// the matching simple keys in key order; sort and use_index are ignored.
+type testStub struct {
+	*shimtest.MockStub
+	txCount int
+	history map[string][]*queryresult.KeyModification // Newest first, as Fabric returns it
+	events  []string                                  // Names of the events set, in order
+
+	lastEventPayload []byte // Payload of the last event set, which is the one Fabric keeps
+
+	rangeReads int // Results read from range scans, so tests can check a scan stops early
+}
+
+func newTestStub() *testStub {
+	return &testStub{
+		MockStub: shimtest.NewMockStub("supplychain", nil),
+		history:  map[string][]*queryresult.KeyModification{},
+	}
+}
+
+// as starts a new transaction and returns a context for a client of the given organization
+func (s *testStub) as(mspID string) *testContext {
+	s.txCount++
+	s.MockTransactionStart(fmt.Sprintf("tx%d", s.txCount))
+	s.TxTimestamp = timestamppb.New(testEpoch.Add(time.Duration(s.txCount) * time.Second))
+	return &testContext{stub: s, identity: &testIdentity{mspID: mspID}}
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	err := s.MockStub.PutState(key, value)
+	if err != nil {
+		return err
+	}
+	s.recordHistory(key, value, false)
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	err := s.MockStub.DelState(key)
+	if err != nil {
+		return err
+	}
+	s.recordHistory(key, nil, true)
+	return nil
+}
+
+func (s *testStub) recordHistory(key string, value []byte, isDelete bool) {
+	modification := &queryresult.KeyModification{
+		TxId:      s.TxID,
+		Value:     value,
+		Timestamp: s.TxTimestamp,
+		IsDelete:  isDelete,
+	}
+	s.history[key] = append([]*queryresult.KeyModification{modification}, s.history[key]...)
+}
+
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &testHistoryIterator{modifications: s.history[key]}, nil
+}
+
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	results, err := s.queryResults(query)
+	if err != nil {
+		return nil, err
+	}
+	return &testStateIterator{results: results}, nil
+}
+
+// GetQueryResultWithPagination pages through the results of GetQueryResult, using the last key returned as the
+// bookmark
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	results, err := s.queryResults(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for bookmark != "" && len(results) > 0 && results[0].Key <= bookmark {
+		results = results[1:]
+	}
+	if len(results) > int(pageSize) {
+		results = results[:pageSize]
+	}
+	metadata := &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(results))}
+	if len(results) > 0 {
+		metadata.Bookmark = results[len(results)-1].Key
+	}
+	return &testStateIterator{results: results}, metadata, nil
+}
+
+// queryResults returns the simple keys whose values match the selector of a rich query, in key order
+func (s *testStub) queryResults(query string) ([]*queryresult.KV, error) {
+	// Decoding into a map keeps the last of duplicate keys, as CouchDB does
+	var parsed struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	err := json.Unmarshal([]byte(query), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+
+	var results []*queryresult.KV
+	for element := s.Keys.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		if strings.HasPrefix(key, "\x00") {
+			continue // Composite keys are never returned by rich queries
+		}
+
+		var document map[string]interface{}
+		if json.Unmarshal(s.State[key], &document) != nil {
+			continue // CouchDB only queries JSON values
+		}
+		document["_id"] = key
+		if matchesSelector(document, parsed.Selector) {
+			results = append(results, &queryresult.KV{Key: key, Value: s.State[key]})
+		}
+	}
+	return results, nil
+}
+
+// matchesSelector evaluates a CouchDB selector against a JSON document
+func matchesSelector(document map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		switch field {
+		case "$or":
+			matched := false
+			for _, alternative := range condition.([]interface{}) {
+				matched = matched || matchesSelector(document, alternative.(map[string]interface{}))
+			}
+			if !matched {
+				return false
+			}
+		case "$and":
+			for _, part := range condition.([]interface{}) {
+				if !matchesSelector(document, part.(map[string]interface{})) {
+					return false
+				}
+			}
+		default:
+			value, present := document[field]
+			if !matchesCondition(value, present, condition) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesCondition evaluates the condition on one field of a document; a condition that is not an operator object
+// is an implicit $eq
+func matchesCondition(value interface{}, present bool, condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+	if !ok {
+		return present && reflect.DeepEqual(value, condition)
+	}
+
+	for operator, argument := range operators {
+		var matched bool
+		switch operator {
+		case "$eq":
+			matched = present && reflect.DeepEqual(value, argument)
+		case "$ne":
+			matched = !present || !reflect.DeepEqual(value, argument)
+		case "$gt", "$gte", "$lt", "$lte":
+			order := compareJSON(value, argument)
+			matched = present && map[string]bool{
+				"$gt": order > 0, "$gte": order >= 0, "$lt": order < 0, "$lte": order <= 0,
+			}[operator]
+		case "$regex":
+			text, isString := value.(string)
+			matched = isString && regexp.MustCompile(argument.(string)).MatchString(text)
+		case "$exists":
+			matched = present == argument.(bool)
+		case "$elemMatch":
+			elements, _ := value.([]interface{})
+			for _, element := range elements {
+				if object, isObject := element.(map[string]interface{}); isObject && !isOperatorObject(argument) {
+					matched = matched || matchesSelector(object, argument.(map[string]interface{}))
+				} else {
+					matched = matched || matchesCondition(element, true, argument)
+				}
+			}
+		default:
+			panic("unsupported selector operator " + operator)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// isOperatorObject reports whether a condition is an object of operators rather than a selector on object fields
+func isOperatorObject(condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for operator := range operators {
+		if !strings.HasPrefix(operator, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// compareJSON orders two JSON values by CouchDB's collation: null, then booleans, numbers, strings, arrays and
+// objects. Strings are compared by code point rather than by CouchDB's Unicode collation, and arrays and objects of
+// the same type compare equal.
+func compareJSON(a, b interface{}) int {
+	rank := func(value interface{}) int {
+		switch value.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		case string:
+			return 3
+		case []interface{}:
+			return 4
+		}
+		return 5
+	}
+	if rank(a) != rank(b) {
+		return rank(a) - rank(b)
+	}
+
+	switch a := a.(type) {
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case a:
+			return 1
+		}
+		return -1
+	case float64:
+		switch {
+		case a < b.(float64):
+			return -1
+		case a > b.(float64):
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func (s *testStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := s.MockStub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return &countingStateIterator{StateQueryIteratorInterface: iterator, reads: &s.rangeReads}, nil
+}
+
+// GetStateByRangeWithPagination pages through GetStateByRange. As in Fabric, the bookmark is the key the next page
+// starts from.
+func (s *testStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	iterator, err := s.MockStub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	var results []*queryresult.KV
+	metadata := &peer.QueryResponseMetadata{}
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(results) == int(pageSize) {
+			metadata.Bookmark = result.Key
+			break
+		}
+		results = append(results, result)
+	}
+	metadata.FetchedRecordsCount = int32(len(results))
+	return &testStateIterator{results: results}, metadata, nil
+}
+
+// SetEvent records the event instead of writing to the MockStub's bounded event channel
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.events = append(s.events, name)
+	s.lastEventPayload = payload
+	return nil
+}
+
+type testStateIterator struct {
+	results []*queryresult.KV
+}
+
+func (i *testStateIterator) HasNext() bool { return len(i.results) > 0 }
+func (i *testStateIterator) Close() error  { return nil }
+func (i *testStateIterator) Next() (*queryresult.KV, error) {
+	if len(i.results) == 0 {
+		return nil, errors.New("no more results")
+	}
+	result := i.results[0]
+	i.results = i.results[1:]
+	return result, nil
+}
+
+// countingStateIterator counts the results read from another iterator
+type countingStateIterator struct {
+	shim.StateQueryIteratorInterface
+	reads *int
+}
+
+func (i *countingStateIterator) Next() (*queryresult.KV, error) {
+	*i.reads++
+	return i.StateQueryIteratorInterface.Next()
+}
+
+type testHistoryIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (i *testHistoryIterator) HasNext() bool { return len(i.modifications) > 0 }
+func (i *testHistoryIterator) Close() error  { return nil }
+func (i *testHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if len(i.modifications) == 0 {
+		return nil, errors.New("no more results")
+	}
+	modification := i.modifications[0]
+	i.modifications = i.modifications[1:]
+	return modification, nil
+}
+
+// testContext is a transaction context for a client of a fixed organization
+type testContext struct {
+	stub     *testStub
+	identity *testIdentity
+}
+
+func (c *testContext) GetStub() shim.ChaincodeStubInterface  { return c.stub }
+func (c *testContext) GetClientIdentity() cid.ClientIdentity { return c.identity }
+
+// testIdentity is a client identity that only has an MSP ID
+type testIdentity struct {
+	mspID string
+}
+
+func (i *testIdentity) GetID() (string, error)    { return "x509::CN=user::CN=ca", nil }
+func (i *testIdentity) GetMSPID() (string, error) { return i.mspID, nil }
+func (i *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	return "", false, nil
+}
+func (i *testIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	return fmt.Errorf("attribute %s not found", attrName)
+}
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+// testDataHash returns a valid data hash for a test payload
+func testDataHash(encryptedData string) string {
+	digest := sha256.Sum256([]byte(encryptedData))
+	return hex.EncodeToString(digest[:])
+}
+
+// createTestData creates a shipment data point owned by ownerMSP and shared with accessControl, failing the test on
+// error
+func createTestData(t *testing.T, stub *testStub, id, ownerMSP string, accessControl ...string) {
+	t.Helper()
+	encryptedData := "payload-" + id
+	_, err := new(SmartContract).CreateSupplyChainData(stub.as(ownerMSP), id, ownerMSP, encryptedData, testDataHash(encryptedData), DataTypeShipment, accessControl, "")
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", id, err)
+	}
+}
+
+// createTestDataAt creates a shipment data point owned by ownerMSP in a transaction whose timestamp is offset past
+// its whole second, e.g. to check sub-second timestamps
+func createTestDataAt(t *testing.T, stub *testStub, id, ownerMSP string, offset time.Duration) {
+	t.Helper()
+	encryptedData := "payload-" + id
+	ctx := stub.as(ownerMSP)
+	stub.TxTimestamp = timestamppb.New(stub.TxTimestamp.AsTime().Add(offset))
+	_, err := new(SmartContract).CreateSupplyChainData(ctx, id, ownerMSP, encryptedData, testDataHash(encryptedData), DataTypeShipment, nil, "")
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", id, err)
+	}
+}
+
+// createExpiringTestData creates a shipment data point owned by ownerMSP that expires half a second after it is
+// created, so it is expired in every later transaction
+func createExpiringTestData(t *testing.T, stub *testStub, id, ownerMSP string) {
+	t.Helper()
+	encryptedData := "payload-" + id
+	ctx := stub.as(ownerMSP)
+	expiresAt := stub.TxTimestamp.AsTime().Add(500 * time.Millisecond).Format(time.RFC3339Nano)
+	err := new(SmartContract).CreateSupplyChainDataWithExpiry(ctx, id, ownerMSP, encryptedData, testDataHash(encryptedData), DataTypeShipment, nil, expiresAt)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", id, err)
+	}
+}
+
+// mustSucceed fails the test if err is not nil
+func mustSucceed(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustFailWith fails the test unless err wraps target
+func mustFailWith(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected error %v, got %v", target, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// schemaMigrations upgrades supply chain data from the schema version used as key to the next one
+var schemaMigrations = map[int]func(*SupplyChainData){
+	1: migrateSchemaV1ToV2,
+}
+
+// MigrateRecords upgrades every supply chain data point at schemaVersion fromVersion to toVersion, filling in the
+// fields added in between, and returns how many were migrated. Data at any other version is left alone, so running
+// the same migration again is safe and migrates nothing. Only the admin organization can migrate records, and each
+// owner's peers must endorse the migration of its data.
+func (s *SmartContract) MigrateRecords(ctx contractapi.TransactionContextInterface, fromVersion, toVersion int) (int, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Verify that the client is the admin organization
+	if !sameOrg(clientOrgID, adminMSPID) {
+		return 0, fmt.Errorf("%w: client from organization %s is not authorized to migrate records", ErrUnauthorized, clientOrgID)
+	}
+
+	if fromVersion < 1 || fromVersion >= toVersion || toVersion > currentSchemaVersion {
+		return 0, fmt.Errorf("%w: cannot migrate from schema version %d to %d, the current schema version is %d", ErrInvalidArgument, fromVersion, toVersion, currentSchemaVersion)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		// Skip if this is not supply chain data (e.g., access policies)
+		if !isSupplyChainDataKey(queryResponse.Key) {
+			continue
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(queryResponse.Value, &supplyChainData)
+		if err != nil {
+			continue // Skip malformed data
+		}
+		if supplyChainData.SchemaVersion != fromVersion {
+			continue
+		}
+
+		// Apply each migration step in turn
+		for version := fromVersion; version < toVersion; version++ {
+			schemaMigrations[version](&supplyChainData)
+			supplyChainData.SchemaVersion = version + 1
+		}
+
+		// Put the data back on the ledger
+		err = putSupplyChainData(ctx, &supplyChainData)
+		if err != nil {
+			return 0, err
+		}
+		migrated++
+	}
+
+	// Emit an event recording the migration
+	err = emitEvent(ctx, "RecordsMigrated", struct {
+		FromVersion int `json:"fromVersion"`
+		ToVersion   int `json:"toVersion"`
+		Migrated    int `json:"migrated"`
+	}{fromVersion, toVersion, migrated})
+	if err != nil {
+		return 0, err
+	}
+
+	return migrated, nil
+}
+
+// migrateSchemaV1ToV2 fills in the encryption scheme, which schema version 1 did not record. Data created by
+// CreateSupplyChainDataSimple is recognized by its generic data type and missing hash.
+func migrateSchemaV1ToV2(supplyChainData *SupplyChainData) {
+	if supplyChainData.EncryptionScheme != "" {
+		return
+	}
+
+	if supplyChainData.DataType == DataTypeSupplyChain && supplyChainData.DataHash == "" {
+		supplyChainData.EncryptionScheme = EncryptionSchemeNone
+	} else {
+		supplyChainData.EncryptionScheme = EncryptionSchemeFernet
+	}
+}
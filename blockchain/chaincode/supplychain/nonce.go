@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// consumeNonce records a client-supplied nonce as used, returning a CONFLICT error if it was already used. Sensitive
+// functions opt in by taking a nonce argument and calling this before making any change; an empty nonce skips the
+// check, so clients that do not need the protection are unaffected.
+//
+// Fabric already rejects a transaction whose ID was committed before, which stops a captured signed proposal from
+// being submitted again. It does not stop the same request from being signed and submitted anew, e.g. when a gateway
+// in front of the peers replays a request it logged. A nonce is tied to the request rather than to the signed
+// transaction, so it also catches those replays.
+func consumeNonce(ctx contractapi.TransactionContextInterface, nonce string) error {
+	if nonce == "" {
+		return nil
+	}
+
+	nonceKey := nonceKeyPrefix + nonce
+	usedBy, err := ctx.GetStub().GetState(nonceKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if usedBy != nil {
+		return fmt.Errorf("%w: nonce %s was already used by transaction %s", ErrConflict, nonce, usedBy)
+	}
+
+	// Remember the transaction that used the nonce, for investigating rejected replays
+	return ctx.GetStub().PutState(nonceKey, []byte(ctx.GetStub().GetTxID()))
+}
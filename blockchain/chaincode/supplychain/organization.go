@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// rejectUnregisteredOrgs makes data creation fail when the access control list names an organization that has not
+// been registered with RegisterOrganization, catching typos that would share data with an organization that can
+// never read it. It is off by default so networks that do not maintain the registry keep working.
+const rejectUnregisteredOrgs = false
+
+// Organization is a known participant of the supply chain network
+type Organization struct {
+	MSPID        string    `json:"mspId"`
+	Name         string    `json:"name"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// RegisterOrganization adds an organization to the registry. Only the admin organization can register organizations.
+func (s *SmartContract) RegisterOrganization(ctx contractapi.TransactionContextInterface, mspID, name string) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is the admin organization
+	if !sameOrg(clientOrgID, adminMSPID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to register organizations", ErrUnauthorized, clientOrgID)
+	}
+
+	mspID = strings.TrimSpace(mspID)
+	if mspID == "" {
+		return fmt.Errorf("%w: MSP ID must not be empty", ErrInvalidArgument)
+	}
+
+	// Check if the organization is already registered
+	registered, err := s.IsRegisteredOrg(ctx, mspID)
+	if err != nil {
+		return err
+	}
+	if registered {
+		return fmt.Errorf("%w: the organization %s is already registered", ErrAlreadyExists, mspID)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the organization object
+	organization := Organization{
+		MSPID:        mspID,
+		Name:         name,
+		RegisteredAt: now,
+	}
+
+	// Convert to JSON
+	organizationJSON, err := json.Marshal(organization)
+	if err != nil {
+		return err
+	}
+
+	// Put the organization on the ledger
+	return ctx.GetStub().PutState(organizationKey(mspID), organizationJSON)
+}
+
+// IsRegisteredOrg returns true when the organization has been registered
+func (s *SmartContract) IsRegisteredOrg(ctx contractapi.TransactionContextInterface, mspID string) (bool, error) {
+	organizationJSON, err := ctx.GetStub().GetState(organizationKey(mspID))
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return organizationJSON != nil, nil
+}
+
+// registeredOrgID returns the MSP ID as spelled when the organization was registered, or mspID itself if it is not
+// registered
+func registeredOrgID(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	organizationJSON, err := ctx.GetStub().GetState(organizationKey(mspID))
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if organizationJSON == nil {
+		return mspID, nil
+	}
+
+	var organization Organization
+	err = json.Unmarshal(organizationJSON, &organization)
+	if err != nil {
+		return "", err
+	}
+
+	return organization.MSPID, nil
+}
+
+// FindOrphanedAccessGrants returns, for each of the organization's supply chain data points including archived ones,
+// the organizations on its access control list that are not registered, e.g. because they left the network. Data
+// without such organizations is left out. Only the organization itself can look for orphaned grants.
+func (s *SmartContract) FindOrphanedAccessGrants(ctx contractapi.TransactionContextInterface, organizationID string) (map[string][]string, error) {
+	// Get all of the organization's data, verifying the client belongs to it
+	results, err := s.querySupplyChainDataByOrg(ctx, organizationID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up each organization in the registry once
+	registeredOrgs := make(map[string]bool)
+	orphanedGrants := make(map[string][]string)
+	for _, supplyChainData := range results {
+		for _, org := range supplyChainData.AccessControl {
+			key := organizationKey(org)
+			registered, ok := registeredOrgs[key]
+			if !ok {
+				registered, err = s.IsRegisteredOrg(ctx, org)
+				if err != nil {
+					return nil, err
+				}
+				registeredOrgs[key] = registered
+			}
+
+			if !registered {
+				orphanedGrants[supplyChainData.ID] = append(orphanedGrants[supplyChainData.ID], org)
+			}
+		}
+	}
+
+	return orphanedGrants, nil
+}
+
+// organizationKey returns the ledger key of an organization in the registry, honoring caseInsensitiveOrgIDs
+func organizationKey(mspID string) string {
+	mspID = strings.TrimSpace(mspID)
+	if caseInsensitiveOrgIDs {
+		mspID = strings.ToUpper(mspID)
+	}
+	return organizationKeyPrefix + mspID
+}
+
+// checkRegisteredOrgs returns an error naming the first organization that is not registered, if
+// rejectUnregisteredOrgs is set
+func (s *SmartContract) checkRegisteredOrgs(ctx contractapi.TransactionContextInterface, orgs []string) error {
+	if !rejectUnregisteredOrgs {
+		return nil
+	}
+
+	for _, org := range orgs {
+		registered, err := s.IsRegisteredOrg(ctx, org)
+		if err != nil {
+			return err
+		}
+		if !registered {
+			return fmt.Errorf("%w: organization %s is not registered", ErrInvalidArgument, org)
+		}
+	}
+
+	return nil
+}
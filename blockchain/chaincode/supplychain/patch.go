@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// immutableFields are the supply chain data fields that identify a record and can never be changed by a patch
+var immutableFields = []string{"id", "organizationId"}
+
+// PatchSupplyChainData updates only the fields present in patchJSON, a JSON object keyed by the JSON field names of
+// SupplyChainData. Only the owning organization can patch its data. The patchable fields are:
+//
+//   - encryptedData: must be patched together with dataHash so the hash keeps matching the payload
+//   - dataHash: hex-encoded SHA-256 digest
+//   - accessControl: may only add organizations, use RevokeDataAccess to remove one; the organization required for
+//     the data type, if any, is always kept
+//   - expiresAt: RFC 3339 time, or an empty string to remove the expiry
+//
+// id and organizationId are immutable; use TransferOwnership to change the owner. Every other field is managed
+// by the contract and cannot be patched either. LastModified is bumped on every patch.
+func (s *SmartContract) PatchSupplyChainData(ctx contractapi.TransactionContextInterface, id, patchJSON string) error {
+	// Parse the patch
+	var patch map[string]json.RawMessage
+	err := json.Unmarshal([]byte(patchJSON), &patch)
+	if err != nil {
+		return fmt.Errorf("%w: patch must be a JSON object: %v", ErrInvalidArgument, err)
+	}
+	if len(patch) == 0 {
+		return fmt.Errorf("%w: patch must not be empty", ErrInvalidArgument)
+	}
+
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// Apply the patch fields in a deterministic order
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		err = applyPatchField(ctx, supplyChainData, field, patch[field])
+		if err != nil {
+			return err
+		}
+	}
+
+	// The payload and its hash are only meaningful together
+	_, hasEncryptedData := patch["encryptedData"]
+	_, hasDataHash := patch["dataHash"]
+	if hasEncryptedData && !hasDataHash {
+		return fmt.Errorf("%w: encryptedData must be patched together with dataHash", ErrInvalidArgument)
+	}
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "PatchSupplyChainData", id)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event listing the patched fields
+	return emitEvent(ctx, "DataPatched", struct {
+		ID     string   `json:"id"`
+		Fields []string `json:"fields"`
+	}{id, fields})
+}
+
+// applyPatchField sets one patchable field of the supply chain data from its JSON value
+func applyPatchField(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData, field string, value json.RawMessage) error {
+	switch field {
+	case "encryptedData":
+		return unmarshalPatchValue(field, value, &supplyChainData.EncryptedData)
+
+	case "dataHash":
+		var dataHash string
+		err := unmarshalPatchValue(field, value, &dataHash)
+		if err != nil {
+			return err
+		}
+		if !isValidDataHash(dataHash) {
+			return fmt.Errorf("%w: data hash must be a %d character hex-encoded SHA-256 digest", ErrInvalidArgument, sha256.Size*2)
+		}
+		supplyChainData.DataHash = dataHash
+
+	case "accessControl":
+		var accessControl []string
+		err := unmarshalPatchValue(field, value, &accessControl)
+		if err != nil {
+			return err
+		}
+		for _, org := range accessControl {
+			if strings.TrimSpace(org) == "" {
+				return fmt.Errorf("%w: access control list must not contain an empty organization", ErrInvalidArgument)
+			}
+		}
+
+		// Always keep the organization required for this data type, if any
+		accessControl, err = withMandatoryAccess(ctx, supplyChainData.DataType, supplyChainData.OrganizationID, normalizeOrgIDs(accessControl))
+		if err != nil {
+			return err
+		}
+		err = checkNoAccessRemoved(supplyChainData, accessControl)
+		if err != nil {
+			return err
+		}
+		supplyChainData.AccessControl = accessControl
+
+	case "expiresAt":
+		var expiresAtRFC3339 string
+		err := unmarshalPatchValue(field, value, &expiresAtRFC3339)
+		if err != nil {
+			return err
+		}
+		if expiresAtRFC3339 == "" {
+			supplyChainData.ExpiresAt = time.Time{}
+			return nil
+		}
+		expiresAt, err := time.Parse(time.RFC3339, expiresAtRFC3339)
+		if err != nil {
+			return fmt.Errorf("%w: invalid expiry time %q, expected RFC 3339: %v", ErrInvalidArgument, expiresAtRFC3339, err)
+		}
+		supplyChainData.ExpiresAt = expiresAt
+
+	default:
+		if contains(immutableFields, field) {
+			return fmt.Errorf("%w: field %s is immutable", ErrInvalidArgument, field)
+		}
+		return fmt.Errorf("%w: field %s cannot be patched", ErrInvalidArgument, field)
+	}
+
+	return nil
+}
+
+// unmarshalPatchValue decodes the JSON value of a patch field, reporting which field was malformed
+func unmarshalPatchValue(field string, value json.RawMessage, target interface{}) error {
+	err := json.Unmarshal(value, target)
+	if err != nil {
+		return fmt.Errorf("%w: invalid value for field %s: %v", ErrInvalidArgument, field, err)
+	}
+	return nil
+}
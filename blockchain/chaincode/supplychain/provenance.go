@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxRelationshipPathDepth is the maximum number of provenance links FindRelationshipPath follows from the start
+const maxRelationshipPathDepth = 8
+
+// CreateSupplyChainDataWithProvenance adds a new supply chain data point derived from existing ones, e.g. a finished
+// good made from components. Every parent must exist and be accessible to the client. With inheritAccess, the new
+// data is also shared with every organization on the parents' access control lists, so partners that followed the
+// parents keep seeing what was derived from them.
+func (s *SmartContract) CreateSupplyChainDataWithProvenance(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl, parentIDs []string, inheritAccess bool) error {
+	// Validate and build the supply chain data object
+	supplyChainData, err := s.newSupplyChainData(ctx, id, organizationID, encryptedData, dataHash, dataType, accessControl)
+	if err != nil {
+		return err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check every parent exists, can be accessed by the client and does not descend from the new data
+	parents, err := getParents(ctx, clientOrgID, id, parentIDs)
+	if err != nil {
+		return err
+	}
+
+	// Only inherit from parents the client was shown to be able to access
+	if inheritAccess {
+		for _, parent := range parents {
+			for _, org := range parent.AccessControl {
+				if !sameOrg(org, supplyChainData.OrganizationID) && !containsOrg(supplyChainData.AccessControl, org) {
+					supplyChainData.AccessControl = append(supplyChainData.AccessControl, org)
+				}
+			}
+		}
+	}
+	supplyChainData.ParentIDs = parentIDs
+
+	// Put the data and its index entries on the ledger
+	return putNewSupplyChainData(ctx, supplyChainData)
+}
+
+// UpdateParentIDs replaces the parents of a supply chain data point, e.g. to correct a parent attached in error.
+// The new parents are checked like those passed to CreateSupplyChainDataWithProvenance. Only the owning organization
+// can update the parents, and access inherited from the old parents is left unchanged.
+func (s *SmartContract) UpdateParentIDs(ctx contractapi.TransactionContextInterface, id string, parentIDs []string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check every parent exists, can be accessed by the client and does not descend from the data
+	_, err = getParents(ctx, clientOrgID, id, parentIDs)
+	if err != nil {
+		return err
+	}
+
+	// Replace the parents
+	oldParentIDs := supplyChainData.ParentIDs
+	supplyChainData.ParentIDs = parentIDs
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "UpdateParentIDs", id)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so provenance consumers can rebuild their view of the chain
+	return emitEvent(ctx, "ProvenanceUpdated", struct {
+		ID           string   `json:"id"`
+		OldParentIDs []string `json:"oldParentIds"`
+		NewParentIDs []string `json:"newParentIds"`
+	}{id, oldParentIDs, parentIDs})
+}
+
+// GetProvenanceChain returns all ancestors of a supply chain data point, nearest first. Ancestors the client cannot
+// access are left out along with their own ancestors, and each ancestor is returned once even if the links form a cycle.
+func (s *SmartContract) GetProvenanceChain(ctx contractapi.TransactionContextInterface, id string) ([]*SupplyChainData, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the parents breadth first, remembering visited IDs to avoid looping on malformed data
+	chain := []*SupplyChainData{}
+	visited := map[string]bool{id: true}
+	queue := append([]string{}, supplyChainData.ParentIDs...)
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		if visited[parentID] {
+			continue
+		}
+		visited[parentID] = true
+
+		parent, err := getSupplyChainData(ctx, parentID)
+		if err != nil {
+			continue // The parent has been deleted
+		}
+		if !canAccess(clientOrgID, parent) {
+			continue
+		}
+
+		// Follow only the links the client may see, so redacted parents stay hidden
+		parent = redactForClient(clientOrgID, parent)
+		chain = append(chain, parent)
+		queue = append(queue, parent.ParentIDs...)
+	}
+
+	return chain, nil
+}
+
+// FindRelationshipPath returns the IDs of the supply chain data on the shortest chain of provenance links between two
+// data points, starting with fromID and ending with toID. Links are followed both from data to its parents and from
+// data to the data derived from it. Only data the client can access is visited and parent links the owner redacted
+// are not followed, so the path never passes through data the client cannot see. An empty list is returned if there
+// is no such path within maxRelationshipPathDepth links.
+func (s *SmartContract) FindRelationshipPath(ctx contractapi.TransactionContextInterface, fromID, toID string) ([]string, error) {
+	// Get the start, enforcing access control
+	from, err := s.ReadSupplyChainData(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	if fromID == toID {
+		return []string{fromID}, nil
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search breadth first, one depth at a time, remembering how each data point was reached
+	previous := map[string]string{fromID: ""}
+	level := []*SupplyChainData{from}
+	for depth := 0; depth < maxRelationshipPathDepth && len(level) > 0; depth++ {
+		next := []*SupplyChainData{}
+		for _, current := range level {
+			neighbors, err := getRelatedData(ctx, clientOrgID, current)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, neighbor := range neighbors {
+				if _, seen := previous[neighbor.ID]; seen {
+					continue
+				}
+				previous[neighbor.ID] = current.ID
+				if neighbor.ID == toID {
+					// Walk back to the start
+					path := []string{}
+					for id := toID; id != ""; id = previous[id] {
+						path = append([]string{id}, path...)
+					}
+					return path, nil
+				}
+				next = append(next, neighbor)
+			}
+		}
+		level = next
+	}
+
+	return []string{}, nil
+}
+
+// getRelatedData returns the parents of supply chain data and the data derived from it that the client can access,
+// redacted for the client. Parent links hidden from the client are not followed in either direction.
+func getRelatedData(ctx contractapi.TransactionContextInterface, clientOrgID string, supplyChainData *SupplyChainData) ([]*SupplyChainData, error) {
+	related := []*SupplyChainData{}
+	for _, parentID := range supplyChainData.ParentIDs {
+		parent, err := getSupplyChainData(ctx, parentID)
+		if errors.Is(err, ErrNotFound) {
+			continue // The parent has been deleted
+		}
+		if err != nil {
+			return nil, err
+		}
+		if canAccess(clientOrgID, parent) {
+			related = append(related, redactForClient(clientOrgID, parent))
+		}
+	}
+
+	// Query the ledger for the data derived from it
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"parentIds": map[string]interface{}{"$elemMatch": map[string]string{"$eq": supplyChainData.ID}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	children, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range filterByAccess(clientOrgID, children) {
+		if contains(child.ParentIDs, supplyChainData.ID) {
+			related = append(related, child)
+		}
+	}
+
+	return related, nil
+}
+
+// AuditParentCycles returns the IDs of the organization's supply chain data, including archived data, that is its own
+// ancestor through ParentIDs. CreateSupplyChainDataWithProvenance and UpdateParentIDs refuse to create cycles, but
+// data written before those checks may still contain them; break them with RepairParentCycle. Only the organization
+// itself can audit its data.
+func (s *SmartContract) AuditParentCycles(ctx contractapi.TransactionContextInterface, organizationID string) ([]string, error) {
+	// Get all of the organization's data, verifying the client belongs to it
+	results, err := s.querySupplyChainDataByOrg(ctx, organizationID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	inCycle := []string{}
+	for _, supplyChainData := range results {
+		cyclic, err := hasAncestor(ctx, supplyChainData.ID, supplyChainData.ID)
+		if err != nil {
+			return nil, err
+		}
+		if cyclic {
+			inCycle = append(inCycle, supplyChainData.ID)
+		}
+	}
+
+	return inCycle, nil
+}
+
+// RepairParentCycle breaks the provenance cycles that run through a supply chain data point by removing from its
+// ParentIDs every parent that descends from it, and returns the removed parent IDs. An INVALID_ARGUMENT error is
+// returned if the data is not part of a cycle. Only the owning organization can repair its data, and not while it is
+// disputed.
+func (s *SmartContract) RepairParentCycle(ctx contractapi.TransactionContextInterface, id string) ([]string, error) {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only the parents that do not lead back to the data
+	oldParentIDs := supplyChainData.ParentIDs
+	parentIDs := []string{}
+	removed := []string{}
+	for _, parentID := range oldParentIDs {
+		cyclic := parentID == id
+		if !cyclic {
+			cyclic, err = hasAncestor(ctx, parentID, id)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if cyclic {
+			removed = append(removed, parentID)
+		} else {
+			parentIDs = append(parentIDs, parentID)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("%w: the supply chain data %s is not part of a provenance cycle", ErrInvalidArgument, id)
+	}
+	supplyChainData.ParentIDs = parentIDs
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeAuditEntry(ctx, "RepairParentCycle", id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emit an event so provenance consumers can rebuild their view of the chain
+	err = emitEvent(ctx, "ProvenanceUpdated", struct {
+		ID           string   `json:"id"`
+		OldParentIDs []string `json:"oldParentIds"`
+		NewParentIDs []string `json:"newParentIds"`
+	}{id, oldParentIDs, parentIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// getParents returns the parents to link to the supply chain data with the given ID, checking every parent exists,
+// can be accessed by the client and does not make the data its own ancestor
+func getParents(ctx contractapi.TransactionContextInterface, clientOrgID, id string, parentIDs []string) ([]*SupplyChainData, error) {
+	parents := make([]*SupplyChainData, 0, len(parentIDs))
+	for _, parentID := range parentIDs {
+		if parentID == id {
+			return nil, fmt.Errorf("%w: the supply chain data %s cannot be its own parent", ErrInvalidArgument, id)
+		}
+		parent, err := getSupplyChainData(ctx, parentID)
+		if err != nil {
+			return nil, fmt.Errorf("%w (parent %s)", err, parentID)
+		}
+		if !canAccess(clientOrgID, parent) {
+			return nil, fmt.Errorf("%w: client from organization %s is not authorized to use %s as a parent", ErrUnauthorized, clientOrgID, parentID)
+		}
+		parents = append(parents, parent)
+	}
+
+	// Reject parents that descend from the data, so provenance chains stay acyclic
+	err := checkNotAncestor(ctx, id, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return parents, nil
+}
+
+// checkNotAncestor returns an error naming the first parent that has id among its ancestors, as linking the data to
+// its own descendant would create a cycle. For new data this can happen when data is deleted and its ID reused.
+func checkNotAncestor(ctx contractapi.TransactionContextInterface, id string, parentIDs []string) error {
+	for _, parentID := range parentIDs {
+		descends, err := hasAncestor(ctx, parentID, id)
+		if err != nil {
+			return err
+		}
+		if descends {
+			return fmt.Errorf("%w: parent %s descends from the supply chain data %s, which would create a provenance cycle", ErrInvalidArgument, parentID, id)
+		}
+	}
+
+	return nil
+}
+
+// hasAncestor returns true if ancestorID is among the ancestors of the supply chain data with the given ID
+func hasAncestor(ctx contractapi.TransactionContextInterface, id, ancestorID string) (bool, error) {
+	// Walk the ancestors breadth first, remembering visited IDs so existing malformed links cannot loop forever
+	visited := map[string]bool{}
+	queue := []string{id}
+	for len(queue) > 0 {
+		currentID := queue[0]
+		queue = queue[1:]
+		if visited[currentID] {
+			continue
+		}
+		visited[currentID] = true
+
+		current, err := getSupplyChainData(ctx, currentID)
+		if errors.Is(err, ErrNotFound) {
+			continue // The ancestor has been deleted
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if contains(current.ParentIDs, ancestorID) {
+			return true, nil
+		}
+		queue = append(queue, current.ParentIDs...)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// linkParents overwrites the parents of supply chain data directly on the ledger, bypassing the cycle checks, like
+// data written before they existed
+func linkParents(t *testing.T, stub *testStub, id string, parentIDs ...string) {
+	t.Helper()
+	dataJSON, err := stub.GetState(id)
+	mustSucceed(t, err)
+	var data SupplyChainData
+	mustSucceed(t, json.Unmarshal(dataJSON, &data))
+	data.ParentIDs = parentIDs
+	dataJSON, err = json.Marshal(data)
+	mustSucceed(t, err)
+	mustSucceed(t, stub.PutState(id, dataJSON))
+}
+
+func TestAuditAndRepairParentCycles(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	// data1 -> data2 -> data3 -> data1 is a cycle, data4 only descends from it
+	for _, id := range []string{"data1", "data2", "data3", "data4"} {
+		createTestData(t, stub, id, "Org1MSP", "Org2MSP")
+	}
+	linkParents(t, stub, "data1", "data2")
+	linkParents(t, stub, "data2", "data3")
+	linkParents(t, stub, "data3", "data1")
+	linkParents(t, stub, "data4", "data1")
+
+	// Only the organization itself can audit its data
+	_, err := s.AuditParentCycles(stub.as("Org2MSP"), "Org1MSP")
+	mustFailWith(t, err, ErrUnauthorized)
+
+	inCycle, err := s.AuditParentCycles(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(inCycle, []string{"data1", "data2", "data3"}) {
+		t.Fatalf("expected data1, data2 and data3 in a cycle, got %q", inCycle)
+	}
+
+	// Only the owner can repair, and only data that is part of a cycle
+	_, err = s.RepairParentCycle(stub.as("Org2MSP"), "data1")
+	mustFailWith(t, err, ErrUnauthorized)
+	_, err = s.RepairParentCycle(stub.as("Org1MSP"), "data4")
+	mustFailWith(t, err, ErrInvalidArgument)
+
+	removed, err := s.RepairParentCycle(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(removed, []string{"data2"}) {
+		t.Fatalf("expected the link to data2 to be removed, got %q", removed)
+	}
+	inCycle, err = s.AuditParentCycles(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if len(inCycle) != 0 {
+		t.Fatalf("expected no cycles after the repair, got %q", inCycle)
+	}
+
+	// The rest of the chain is kept
+	chain, err := s.GetProvenanceChain(stub.as("Org1MSP"), "data2")
+	mustSucceed(t, err)
+	if len(chain) != 2 || chain[0].ID != "data3" || chain[1].ID != "data1" {
+		t.Fatalf("expected data2 to still descend from data3 and data1, got %d ancestors", len(chain))
+	}
+}
+
+func TestFindRelationshipPath(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createWithParents := func(id string, accessControl []string, parentIDs ...string) {
+		t.Helper()
+		mustSucceed(t, s.CreateSupplyChainDataWithProvenance(stub.as("Org1MSP"), id, "Org1MSP", "payload-"+id, testDataHash("payload-"+id), DataTypeShipment, accessControl, parentIDs, false))
+	}
+
+	// batch1 and batch2 were both made from component1; batch3 was made from the private component2
+	createTestData(t, stub, "component1", "Org1MSP", "Org2MSP")
+	createTestData(t, stub, "component2", "Org1MSP")
+	createWithParents("batch1", []string{"Org2MSP"}, "component1")
+	createWithParents("batch2", []string{"Org2MSP"}, "component1")
+	createWithParents("batch3", []string{"Org2MSP"}, "component2")
+	createWithParents("shipment1", []string{"Org2MSP"}, "batch2", "batch3")
+
+	path, err := s.FindRelationshipPath(stub.as("Org2MSP"), "batch1", "shipment1")
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(path, []string{"batch1", "component1", "batch2", "shipment1"}) {
+		t.Fatalf("unexpected path %q", path)
+	}
+	path, err = s.FindRelationshipPath(stub.as("Org2MSP"), "batch1", "batch1")
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(path, []string{"batch1"}) {
+		t.Fatalf("expected the path from data to itself to be the data alone, got %q", path)
+	}
+
+	// Data the client cannot access is not passed through
+	path, err = s.FindRelationshipPath(stub.as("Org1MSP"), "batch3", "batch1")
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(path, []string{"batch3", "shipment1", "batch2", "component1", "batch1"}) {
+		t.Fatalf("unexpected path %q", path)
+	}
+	createWithParents("batch4", []string{"Org2MSP"}, "component2")
+	path, err = s.FindRelationshipPath(stub.as("Org2MSP"), "batch3", "batch4")
+	mustSucceed(t, err)
+	if len(path) != 0 {
+		t.Fatalf("expected no path through component2, got %q", path)
+	}
+
+	// Neither are redacted parent links
+	mustSucceed(t, s.SetRedactedFields(stub.as("Org1MSP"), "batch2", []string{"parentIds"}))
+	path, err = s.FindRelationshipPath(stub.as("Org2MSP"), "batch1", "shipment1")
+	mustSucceed(t, err)
+	if len(path) != 0 {
+		t.Fatalf("expected no path through the redacted link, got %q", path)
+	}
+	path, err = s.FindRelationshipPath(stub.as("Org1MSP"), "batch1", "shipment1")
+	mustSucceed(t, err)
+	if len(path) != 4 {
+		t.Fatalf("expected the owner to still find the path, got %q", path)
+	}
+
+	// The start must be accessible
+	_, err = s.FindRelationshipPath(stub.as("Org3MSP"), "batch1", "shipment1")
+	if err == nil {
+		t.Fatal("expected an error for an organization without access")
+	}
+}
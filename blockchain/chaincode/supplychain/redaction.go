@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// redactableFields lists the JSON names of the supply chain data fields an owner can hide from grantees. Fields that
+// reads and integrity checks depend on, such as dataHash and accessControl, are always shared.
+var redactableFields = []string{"anomalyMetadata", "disputeReason", "encryptedData", "explanation", "parentIds", "tags"}
+
+// SetRedactedFields sets the fields of a supply chain data point that are blanked when organizations other than the
+// owner read it, so one data point can be shared with some details kept private. Pass an empty list to share every
+// field again. Only the owning organization can set redacted fields, and it always sees every field.
+func (s *SmartContract) SetRedactedFields(ctx contractapi.TransactionContextInterface, id string, fields []string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// Validate the fields, dropping duplicates
+	redactedFields := []string{}
+	for _, field := range fields {
+		if !contains(redactableFields, field) {
+			return fmt.Errorf("%w: field %q cannot be redacted, redactable fields are %v", ErrInvalidArgument, field, redactableFields)
+		}
+		if !contains(redactedFields, field) {
+			redactedFields = append(redactedFields, field)
+		}
+	}
+	sort.Strings(redactedFields)
+	supplyChainData.RedactedFields = redactedFields
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	return writeAuditEntry(ctx, "SetRedactedFields", id)
+}
+
+// redactForClient returns the supply chain data as the client's organization may see it: unchanged for the owner,
+// otherwise a copy with the redacted fields blanked. The copy must never be written back to the ledger.
+func redactForClient(clientOrgID string, supplyChainData *SupplyChainData) *SupplyChainData {
+	if sameOrg(clientOrgID, supplyChainData.OrganizationID) {
+		return supplyChainData
+	}
+	return redactFields(supplyChainData, supplyChainData.RedactedFields)
+}
+
+// redactHistoricalVersion returns a past version of supply chain data as the client's organization may see it,
+// judged by the latest version: unchanged for the current owner, otherwise a copy with both the fields redacted now
+// and those redacted in that version blanked
+func redactHistoricalVersion(clientOrgID string, latest, version *SupplyChainData) *SupplyChainData {
+	if sameOrg(clientOrgID, latest.OrganizationID) {
+		return version
+	}
+	return redactFields(version, append(append([]string{}, latest.RedactedFields...), version.RedactedFields...))
+}
+
+// redactFields returns a copy of the supply chain data with the given fields blanked, or the data itself if there
+// are none
+func redactFields(supplyChainData *SupplyChainData, fields []string) *SupplyChainData {
+	if len(fields) == 0 {
+		return supplyChainData
+	}
+
+	redacted := *supplyChainData
+	for _, field := range fields {
+		switch field {
+		case "anomalyMetadata":
+			redacted.AnomalyMetadata = ""
+		case "disputeReason":
+			redacted.DisputeReason = ""
+		case "encryptedData":
+			redacted.EncryptedData = ""
+		case "explanation":
+			redacted.Explanation = ""
+		case "parentIds":
+			redacted.ParentIDs = nil
+		case "tags":
+			redacted.Tags = nil
+		}
+	}
+	return &redacted
+}
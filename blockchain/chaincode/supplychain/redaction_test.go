@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// setUpRedactedData creates root1 <- redacted1 <- child1, all owned by Org1MSP and shared with Org2MSP. Every
+// redactable field of redacted1 is set, the dispute reason after the owner redacts them all, since disputed data
+// cannot be changed.
+func setUpRedactedData(t *testing.T) *testStub {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "root1", "Org1MSP", "Org2MSP")
+	mustSucceed(t, s.CreateSupplyChainDataWithProvenance(stub.as("Org1MSP"), "redacted1", "Org1MSP", "secret-payload", testDataHash("secret-payload"), DataTypeShipment, []string{"Org2MSP"}, []string{"root1"}, false))
+	mustSucceed(t, s.CreateSupplyChainDataWithProvenance(stub.as("Org1MSP"), "child1", "Org1MSP", "child-payload", testDataHash("child-payload"), DataTypeShipment, []string{"Org2MSP"}, []string{"redacted1"}, false))
+
+	mustSucceed(t, s.AddTag(stub.as("Org1MSP"), "redacted1", "secret-tag"))
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "redacted1")
+	mustSucceed(t, err)
+	mustSucceed(t, s.UpdateAnomalyStatus(stub.as("Org1MSP"), "redacted1", true, 0.99, "secret explanation", `{"feature":"secret"}`, data.Version))
+	mustSucceed(t, s.SetRedactedFields(stub.as("Org1MSP"), "redacted1", redactableFields))
+	mustSucceed(t, s.MarkDisputed(stub.as("Org2MSP"), "redacted1", "secret dispute"))
+
+	return stub
+}
+
+// checkRedacted fails the test if any redactable field of redacted1 is visible in data
+func checkRedacted(t *testing.T, where string, data *SupplyChainData) {
+	t.Helper()
+	if data.AnomalyMetadata != "" || data.DisputeReason != "" || data.EncryptedData != "" || data.Explanation != "" ||
+		len(data.ParentIDs) != 0 || len(data.Tags) != 0 {
+		t.Errorf("%s: redacted field visible to grantee: %+v", where, data)
+	}
+}
+
+// checkRedactedInResults fails the test unless redacted1 is in results with every redactable field hidden
+func checkRedactedInResults(t *testing.T, where string, results []*SupplyChainData, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", where, err)
+	}
+	found := false
+	for _, data := range results {
+		if data.ID == "redacted1" {
+			found = true
+			checkRedacted(t, where, data)
+		}
+	}
+	if !found {
+		t.Errorf("%s: redacted1 not returned", where)
+	}
+}
+
+func TestGranteeNeverSeesRedactedFields(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpRedactedData(t)
+
+	data, err := s.ReadSupplyChainData(stub.as("Org2MSP"), "redacted1")
+	mustSucceed(t, err)
+	checkRedacted(t, "ReadSupplyChainData", data)
+
+	meta, err := s.ReadSupplyChainDataWithMeta(stub.as("Org2MSP"), "redacted1")
+	mustSucceed(t, err)
+	checkRedacted(t, "ReadSupplyChainDataWithMeta", meta.Data)
+
+	results, err := s.ReadSupplyChainDataBatch(stub.as("Org2MSP"), []string{"redacted1"})
+	checkRedactedInResults(t, "ReadSupplyChainDataBatch", results, err)
+
+	results, err = s.QuerySupplyChainDataByType(stub.as("Org2MSP"), DataTypeShipment)
+	checkRedactedInResults(t, "QuerySupplyChainDataByType", results, err)
+
+	results, err = s.QueryAnomalies(stub.as("Org2MSP"))
+	checkRedactedInResults(t, "QueryAnomalies", results, err)
+
+	results, err = s.GetRecentAnomalies(stub.as("Org2MSP"), 10)
+	checkRedactedInResults(t, "GetRecentAnomalies", results, err)
+
+	results, err = s.QueryAnomaliesByThreshold(stub.as("Org2MSP"), 0.5)
+	checkRedactedInResults(t, "QueryAnomaliesByThreshold", results, err)
+
+	results, err = s.QueryAnomaliesByScoreRange(stub.as("Org2MSP"), 0.5, 1)
+	checkRedactedInResults(t, "QueryAnomaliesByScoreRange", results, err)
+
+	results, err = s.QueryAnomaliesBySeverity(stub.as("Org2MSP"), SeverityCritical)
+	checkRedactedInResults(t, "QueryAnomaliesBySeverity", results, err)
+
+	results, err = s.QueryDataSharedWithMe(stub.as("Org2MSP"))
+	checkRedactedInResults(t, "QueryDataSharedWithMe", results, err)
+
+	results, err = s.QueryByTag(stub.as("Org2MSP"), "secret-tag")
+	checkRedactedInResults(t, "QueryByTag", results, err)
+
+	results, err = s.QueryWithSelector(stub.as("Org2MSP"), `{"dataType":"shipment"}`)
+	checkRedactedInResults(t, "QueryWithSelector", results, err)
+
+	results, err = s.QuerySupplyChainData(stub.as("Org2MSP"), `{"dataType":"shipment"}`)
+	checkRedactedInResults(t, "QuerySupplyChainData", results, err)
+
+	results, err = s.FindByDataHash(stub.as("Org2MSP"), testDataHash("secret-payload"))
+	checkRedactedInResults(t, "FindByDataHash", results, err)
+
+	results, err = s.QuerySupplyChainDataByIDRange(stub.as("Org2MSP"), "a", "z")
+	checkRedactedInResults(t, "QuerySupplyChainDataByIDRange", results, err)
+
+	results, err = s.GetAllSupplyChainData(stub.as("Org2MSP"))
+	checkRedactedInResults(t, "GetAllSupplyChainData", results, err)
+
+	results, err = s.GetAllSupplyChainDataIncludingArchived(stub.as("Org2MSP"))
+	checkRedactedInResults(t, "GetAllSupplyChainDataIncludingArchived", results, err)
+
+	page, err := s.GetAllSupplyChainDataPaginated(stub.as("Org2MSP"), 100, "")
+	mustSucceed(t, err)
+	checkRedactedInResults(t, "GetAllSupplyChainDataPaginated", page.Records, nil)
+
+	exportJSON, err := s.ExportAccessibleData(stub.as("Org2MSP"))
+	mustSucceed(t, err)
+	results = nil
+	mustSucceed(t, json.Unmarshal([]byte(exportJSON), &results))
+	checkRedactedInResults(t, "ExportAccessibleData", results, nil)
+
+	// Versions written before the fields were redacted must not leak them either
+	history, err := s.GetSupplyChainDataHistory(stub.as("Org2MSP"), "redacted1")
+	mustSucceed(t, err)
+	if len(history) < 2 {
+		t.Fatalf("GetSupplyChainDataHistory: expected several versions, got %d", len(history))
+	}
+	for _, entry := range history {
+		if entry.Value != nil {
+			checkRedacted(t, "GetSupplyChainDataHistory", entry.Value)
+		}
+	}
+
+	// The redacted parent links must not be followed either
+	chain, err := s.GetProvenanceChain(stub.as("Org2MSP"), "child1")
+	checkRedactedInResults(t, "GetProvenanceChain", chain, err)
+	for _, data := range chain {
+		if data.ID == "root1" {
+			t.Errorf("GetProvenanceChain: followed a redacted parent link to root1")
+		}
+	}
+}
+
+func TestOwnerSeesRedactedFields(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpRedactedData(t)
+
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "redacted1")
+	mustSucceed(t, err)
+	if data.Explanation != "secret explanation" || data.EncryptedData != "secret-payload" || len(data.ParentIDs) != 1 {
+		t.Errorf("owner should see every field, got %+v", data)
+	}
+
+	chain, err := s.GetProvenanceChain(stub.as("Org1MSP"), "child1")
+	mustSucceed(t, err)
+	if len(chain) != 2 {
+		t.Errorf("owner should see the full provenance chain, got %d records", len(chain))
+	}
+}
+
+func TestGetAllSupplyChainDataOnlyReturnsAccessibleData(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpRedactedData(t)
+	createTestData(t, stub, "other1", "Org3MSP")
+
+	for name, getAll := range map[string]func(*testContext) ([]*SupplyChainData, error){
+		"GetAllSupplyChainData": func(ctx *testContext) ([]*SupplyChainData, error) { return s.GetAllSupplyChainData(ctx) },
+		"GetAllSupplyChainDataIncludingArchived": func(ctx *testContext) ([]*SupplyChainData, error) {
+			return s.GetAllSupplyChainDataIncludingArchived(ctx)
+		},
+		"GetAllSupplyChainDataPaginated": func(ctx *testContext) ([]*SupplyChainData, error) {
+			page, err := s.GetAllSupplyChainDataPaginated(ctx, 100, "")
+			if err != nil {
+				return nil, err
+			}
+			return page.Records, nil
+		},
+	} {
+		results, err := getAll(stub.as("Org3MSP"))
+		mustSucceed(t, err)
+		if len(results) != 1 || results[0].ID != "other1" {
+			t.Errorf("%s: expected only the client's own data, got %d records", name, len(results))
+		}
+	}
+}
+
+func TestReadPrivateDataHonorsRedaction(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	ctx := stub.as("Org1MSP")
+	stub.TransientMap = map[string][]byte{encryptedDataTransientKey: []byte("private-payload")}
+	mustSucceed(t, s.CreateSupplyChainDataPrivate(ctx, "private1", "Org1MSP", testDataHash("private-payload"), DataTypeShipment, []string{"Org2MSP"}))
+	stub.TransientMap = nil
+
+	payload, err := s.ReadPrivateData(stub.as("Org2MSP"), "private1")
+	mustSucceed(t, err)
+	if payload != "private-payload" {
+		t.Fatalf("expected the grantee to read the payload, got %q", payload)
+	}
+
+	mustSucceed(t, s.SetRedactedFields(stub.as("Org1MSP"), "private1", []string{"encryptedData"}))
+	_, err = s.ReadPrivateData(stub.as("Org2MSP"), "private1")
+	mustFailWith(t, err, ErrUnauthorized)
+
+	payload, err = s.ReadPrivateData(stub.as("Org1MSP"), "private1")
+	mustSucceed(t, err)
+	if payload != "private-payload" {
+		t.Fatalf("expected the owner to read the payload, got %q", payload)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// resolutionQuorum is the number of distinct organizations that must approve before a detected anomaly is cleared.
+// When fewer organizations can access the data, all of them must approve.
+const resolutionQuorum = 2
+
+// ResolutionApprovers lists the organizations that must approve every anomaly resolution of an organization's data
+type ResolutionApprovers struct {
+	OrganizationID string    `json:"organizationId"` // Owner of the data the requirement applies to
+	ApproverOrgs   []string  `json:"approverOrgs"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// ProposeAnomalyResolution proposes clearing the detected anomaly of a supply chain data point. The proposal counts
+// as the proposing organization's approval; the anomaly is cleared once resolutionQuorum organizations approve,
+// including every approver the owner required with RequireApproversForResolution.
+// Proposals and approvals are written to the data itself, so they need the endorsement of a peer of the owner.
+func (s *SmartContract) ProposeAnomalyResolution(ctx contractapi.TransactionContextInterface, id string) error {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, id, false)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if !supplyChainData.AnomalyDetected {
+		return fmt.Errorf("%w: the supply chain data %s has no detected anomaly to resolve", ErrConflict, id)
+	}
+	if supplyChainData.ResolutionProposedBy != "" {
+		return fmt.Errorf("%w: a resolution for the supply chain data %s was already proposed by organization %s", ErrAlreadyExists, id, supplyChainData.ResolutionProposedBy)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Record the proposal along with the proposer's approval
+	supplyChainData.ResolutionProposedBy = clientOrgID
+	supplyChainData.ResolutionApprovals = []string{clientOrgID}
+
+	return s.applyResolutionApprovals(ctx, supplyChainData, "ProposeAnomalyResolution", "AnomalyResolutionProposed")
+}
+
+// ApproveAnomalyResolution adds the client organization's approval to the pending resolution of a supply chain
+// data point's anomaly. Any organization that can access the data may approve, once.
+func (s *SmartContract) ApproveAnomalyResolution(ctx contractapi.TransactionContextInterface, id string) error {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, id, false)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if supplyChainData.ResolutionProposedBy == "" {
+		return fmt.Errorf("%w: there is no pending resolution for the supply chain data %s", ErrNotFound, id)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Each organization's approval is only counted once
+	if containsOrg(supplyChainData.ResolutionApprovals, clientOrgID) {
+		return fmt.Errorf("%w: organization %s has already approved the resolution for the supply chain data %s", ErrConflict, clientOrgID, id)
+	}
+	supplyChainData.ResolutionApprovals = append(supplyChainData.ResolutionApprovals, clientOrgID)
+
+	return s.applyResolutionApprovals(ctx, supplyChainData, "ApproveAnomalyResolution", "AnomalyResolutionApproved")
+}
+
+// RequireApproversForResolution sets the organizations that must approve the resolution of every anomaly of the
+// organization's supply chain data, on top of the quorum, e.g. a compliance or quality assurance organization. An
+// empty approverOrgs list removes the requirement. Approving needs access to the data, so the data must be shared
+// with the required approvers, or its anomalies cannot be resolved. Only the organization itself can set its required
+// approvers, and they apply to resolutions that are pending as well as to new ones.
+func (s *SmartContract) RequireApproversForResolution(ctx contractapi.TransactionContextInterface, organizationID string, approverOrgs []string) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if the client is allowed to set the required approvers for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to set the required resolution approvers for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	for _, org := range approverOrgs {
+		if strings.TrimSpace(org) == "" {
+			return fmt.Errorf("%w: approver list must not contain an empty organization", ErrInvalidArgument)
+		}
+	}
+
+	approverOrgs = normalizeOrgIDs(approverOrgs)
+	if len(approverOrgs) == 0 {
+		err = ctx.GetStub().DelState(resolutionApproversKey(clientOrgID))
+		if err != nil {
+			return fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		return nil
+	}
+
+	// Catch organizations that are not part of the network
+	err = s.checkRegisteredOrgs(ctx, approverOrgs)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the required approvers object
+	resolutionApprovers := ResolutionApprovers{
+		OrganizationID: clientOrgID,
+		ApproverOrgs:   approverOrgs,
+		UpdatedAt:      now,
+	}
+
+	// Convert to JSON
+	resolutionApproversJSON, err := json.Marshal(resolutionApprovers)
+	if err != nil {
+		return err
+	}
+
+	// Put the required approvers on the ledger
+	return ctx.GetStub().PutState(resolutionApproversKey(clientOrgID), resolutionApproversJSON)
+}
+
+// GetMissingResolutionApprovals returns the required approvers that have not yet approved the pending resolution of a
+// supply chain data point's anomaly. The quorum may still need further approvals when the list is empty.
+func (s *SmartContract) GetMissingResolutionApprovals(ctx contractapi.TransactionContextInterface, id string) ([]string, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if supplyChainData.ResolutionProposedBy == "" {
+		return nil, fmt.Errorf("%w: there is no pending resolution for the supply chain data %s", ErrNotFound, id)
+	}
+
+	requiredApprovers, err := getRequiredApprovers(ctx, supplyChainData.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return missingApprovals(supplyChainData, requiredApprovers), nil
+}
+
+// applyResolutionApprovals clears the anomaly if the approvals reach the quorum and include every required approver,
+// then saves the data, records the operation in the audit trail and emits an AnomalyResolved event, or eventName if
+// the resolution still needs approvals
+func (s *SmartContract) applyResolutionApprovals(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData, operation, eventName string) error {
+	requiredApprovers, err := getRequiredApprovers(ctx, supplyChainData.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	approvals := supplyChainData.ResolutionApprovals
+	quorum, missing, resolved := resolveIfApproved(supplyChainData, requiredApprovers)
+	if resolved {
+		eventName = "AnomalyResolved"
+	}
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, operation, supplyChainData.ID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so the other organizations know the state of the resolution
+	return emitEvent(ctx, eventName, struct {
+		ID        string   `json:"id"`
+		Approvals []string `json:"approvals"`
+		Quorum    int      `json:"quorum"`
+		Missing   []string `json:"missing"` // Required approvers that have not approved yet
+		Resolved  bool     `json:"resolved"`
+	}{supplyChainData.ID, approvals, quorum, missing, resolved})
+}
+
+// resolveIfApproved clears the anomaly of the supply chain data if the approvals of its pending resolution reach the
+// quorum and include every required approver. It returns the quorum, the required approvers still missing and
+// whether the anomaly was cleared; the caller saves the data.
+func resolveIfApproved(supplyChainData *SupplyChainData, requiredApprovers []string) (int, []string, bool) {
+	// The quorum cannot exceed the number of organizations able to approve
+	quorum := resolutionQuorum
+	if parties := len(removeOrg(supplyChainData.AccessControl, supplyChainData.OrganizationID)) + 1; parties < quorum {
+		quorum = parties
+	}
+
+	missing := missingApprovals(supplyChainData, requiredApprovers)
+	if len(supplyChainData.ResolutionApprovals) < quorum || len(missing) > 0 {
+		return quorum, missing, false
+	}
+
+	supplyChainData.AnomalyDetected = false
+	supplyChainData.Severity = ""
+	supplyChainData.ResolutionProposedBy = ""
+	supplyChainData.ResolutionApprovals = nil
+	return quorum, missing, true
+}
+
+// missingApprovals returns the required approvers that have not approved the pending resolution of the supply chain
+// data
+func missingApprovals(supplyChainData *SupplyChainData, requiredApprovers []string) []string {
+	missing := []string{}
+	for _, org := range requiredApprovers {
+		if !containsOrg(supplyChainData.ResolutionApprovals, org) {
+			missing = append(missing, org)
+		}
+	}
+	return missing
+}
+
+// getRequiredApprovers returns the organizations that must approve the anomaly resolutions of an organization's data,
+// or nil if it has not required any
+func getRequiredApprovers(ctx contractapi.TransactionContextInterface, organizationID string) ([]string, error) {
+	resolutionApproversJSON, err := ctx.GetStub().GetState(resolutionApproversKey(organizationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if resolutionApproversJSON == nil {
+		return nil, nil
+	}
+
+	var resolutionApprovers ResolutionApprovers
+	err = json.Unmarshal(resolutionApproversJSON, &resolutionApprovers)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolutionApprovers.ApproverOrgs, nil
+}
+
+// resolutionApproversKey returns the ledger key of an organization's required resolution approvers, honoring
+// caseInsensitiveOrgIDs
+func resolutionApproversKey(organizationID string) string {
+	if caseInsensitiveOrgIDs {
+		organizationID = strings.ToUpper(organizationID)
+	}
+	return resolutionApproversKeyPrefix + organizationID
+}
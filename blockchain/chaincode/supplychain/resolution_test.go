@@ -0,0 +1,146 @@
+package main
+
+import "testing"
+
+// setUpDetectedAnomaly creates data1 owned by Org1MSP, shared with Org2MSP and Org3MSP, with a detected anomaly
+func setUpDetectedAnomaly(t *testing.T) *testStub {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP", "Org3MSP")
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	mustSucceed(t, s.UpdateAnomalyStatus(stub.as("Org1MSP"), "data1", true, 0.95, "temperature excursion", "", data.Version))
+
+	return stub
+}
+
+// anomalyDetected reports whether data1 still has a detected anomaly
+func anomalyDetected(t *testing.T, stub *testStub) bool {
+	t.Helper()
+	data, err := new(SmartContract).ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	return data.AnomalyDetected
+}
+
+func TestUpdateAnomalyStatusCannotClearDetectedAnomaly(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDetectedAnomaly(t)
+
+	for _, org := range []string{"Org1MSP", "Org2MSP"} {
+		data, err := s.ReadSupplyChainData(stub.as(org), "data1")
+		mustSucceed(t, err)
+
+		err = s.UpdateAnomalyStatus(stub.as(org), "data1", false, 0.1, "looks fine", "", data.Version)
+		mustFailWith(t, err, ErrConflict)
+	}
+	if !anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was cleared without the resolution quorum")
+	}
+
+	// A new report of the anomaly is still accepted
+	data, err := s.ReadSupplyChainData(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+	mustSucceed(t, s.UpdateAnomalyStatus(stub.as("Org2MSP"), "data1", true, 0.97, "still out of range", "", data.Version))
+}
+
+func TestAnomalyResolutionNeedsQuorum(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDetectedAnomaly(t)
+
+	mustSucceed(t, s.ProposeAnomalyResolution(stub.as("Org2MSP"), "data1"))
+	if !anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was cleared by the proposer alone")
+	}
+
+	mustFailWith(t, s.ApproveAnomalyResolution(stub.as("Org2MSP"), "data1"), ErrConflict)
+
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org3MSP"), "data1"))
+	if anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was not cleared once the quorum approved")
+	}
+}
+
+func TestClearAnomaliesNeedsQuorum(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDetectedAnomaly(t)
+
+	// The owner alone only proposes the resolution
+	mustSucceed(t, s.ClearAnomalies(stub.as("Org1MSP"), []string{"data1"}))
+	if !anomalyDetected(t, stub) {
+		t.Fatal("the owner cleared the anomaly without the resolution quorum")
+	}
+
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org2MSP"), "data1"))
+	if anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was not cleared once the quorum approved")
+	}
+}
+
+func TestClearAnomaliesOfUnsharedData(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	// With no other organization to approve, the owner's approval is the quorum
+	createTestData(t, stub, "data1", "Org1MSP")
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	mustSucceed(t, s.UpdateAnomalyStatus(stub.as("Org1MSP"), "data1", true, 0.95, "temperature excursion", "", data.Version))
+
+	mustSucceed(t, s.ClearAnomalies(stub.as("Org1MSP"), []string{"data1"}))
+	data, err = s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if data.AnomalyDetected || data.AnomalyScore != 0 || data.Explanation != "temperature excursion ("+falsePositiveNote+")" {
+		t.Fatalf("the anomaly was not cleared as a false positive: %+v", data)
+	}
+}
+
+func TestResolutionNeedsRequiredApprovers(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDetectedAnomaly(t)
+
+	// Only the organization itself can require approvers for its data
+	mustFailWith(t, s.RequireApproversForResolution(stub.as("Org2MSP"), "Org1MSP", []string{"Org2MSP"}), ErrUnauthorized)
+	mustSucceed(t, s.RequireApproversForResolution(stub.as("Org1MSP"), "Org1MSP", []string{"Org3MSP"}))
+
+	// The quorum alone is not enough
+	mustSucceed(t, s.ProposeAnomalyResolution(stub.as("Org2MSP"), "data1"))
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org1MSP"), "data1"))
+	if !anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was cleared without the required approver")
+	}
+	missing, err := s.GetMissingResolutionApprovals(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+	if len(missing) != 1 || missing[0] != "Org3MSP" {
+		t.Fatalf("expected Org3MSP to be missing, got %v", missing)
+	}
+
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org3MSP"), "data1"))
+	if anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was not cleared once the required approver approved")
+	}
+}
+
+func TestClearAnomaliesNeedsRequiredApprovers(t *testing.T) {
+	s := new(SmartContract)
+	stub := setUpDetectedAnomaly(t)
+	mustSucceed(t, s.RequireApproversForResolution(stub.as("Org1MSP"), "Org1MSP", []string{"Org3MSP"}))
+
+	mustSucceed(t, s.ClearAnomalies(stub.as("Org1MSP"), []string{"data1"}))
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org2MSP"), "data1"))
+	if !anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was cleared without the required approver")
+	}
+
+	// Removing the requirement leaves the pending resolution to the quorum
+	mustSucceed(t, s.RequireApproversForResolution(stub.as("Org1MSP"), "Org1MSP", nil))
+	missing, err := s.GetMissingResolutionApprovals(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if len(missing) != 0 {
+		t.Fatalf("expected no missing approvals, got %v", missing)
+	}
+	mustSucceed(t, s.ApproveAnomalyResolution(stub.as("Org3MSP"), "data1"))
+	if anomalyDetected(t, stub) {
+		t.Fatal("the anomaly was not cleared once the quorum approved")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RetentionPolicy sets how long an organization keeps a type of data before it is archived
+type RetentionPolicy struct {
+	OrganizationID string    `json:"organizationId"`
+	DataType       string    `json:"dataType"`
+	RetentionDays  int       `json:"retentionDays"` // Days after creation at which the data is archived
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// SetRetentionPolicy sets the number of days an organization's data of the given type is kept before
+// EnforceRetention archives it. Only the organization itself can set its retention policies.
+func (s *SmartContract) SetRetentionPolicy(ctx contractapi.TransactionContextInterface, organizationID, dataType string, retentionDays int) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check if the client is allowed to set retention policies for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to set retention policies for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	err = ValidateDataType(dataType)
+	if err != nil {
+		return err
+	}
+	if retentionDays <= 0 {
+		return fmt.Errorf("%w: retention days must be positive, got %d", ErrInvalidArgument, retentionDays)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the retention policy object
+	retentionPolicy := RetentionPolicy{
+		OrganizationID: clientOrgID,
+		DataType:       dataType,
+		RetentionDays:  retentionDays,
+		UpdatedAt:      now,
+	}
+
+	// Convert to JSON
+	retentionPolicyJSON, err := json.Marshal(retentionPolicy)
+	if err != nil {
+		return err
+	}
+
+	// Put the retention policy on the ledger
+	return ctx.GetStub().PutState(retentionPolicyKey(clientOrgID, dataType), retentionPolicyJSON)
+}
+
+// EnforceRetention archives the organization's data that is older than the retention policy set for its type and
+// returns the number of data points archived. Data types without a retention policy are kept indefinitely.
+// Only the organization itself can enforce its retention policies.
+func (s *SmartContract) EnforceRetention(ctx contractapi.TransactionContextInterface, organizationID string) (int, error) {
+	// Get the organization's unarchived data, verifying the client belongs to it
+	results, err := s.querySupplyChainDataByOrg(ctx, organizationID, false)
+	if err != nil {
+		return 0, err
+	}
+
+	// Compare against the transaction timestamp so every endorsing peer archives the same data
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Look up each data type's policy once
+	retentionPolicies := make(map[string]*RetentionPolicy)
+	archivedIDs := []string{}
+	for _, supplyChainData := range results {
+		retentionPolicy, ok := retentionPolicies[supplyChainData.DataType]
+		if !ok {
+			retentionPolicy, err = getRetentionPolicy(ctx, supplyChainData.OrganizationID, supplyChainData.DataType)
+			if err != nil {
+				return 0, err
+			}
+			retentionPolicies[supplyChainData.DataType] = retentionPolicy
+		}
+		if retentionPolicy == nil {
+			continue
+		}
+
+		if !now.After(supplyChainData.Timestamp.AddDate(0, 0, retentionPolicy.RetentionDays)) {
+			continue
+		}
+
+		// Disputed data cannot be changed; it is archived by the first run after the dispute is resolved
+		if supplyChainData.Disputed {
+			continue
+		}
+
+		// Archive the data
+		supplyChainData.Archived = true
+		err = putSupplyChainData(ctx, supplyChainData)
+		if err != nil {
+			return 0, err
+		}
+		err = writeAuditEntry(ctx, "EnforceRetention", supplyChainData.ID)
+		if err != nil {
+			return 0, err
+		}
+		archivedIDs = append(archivedIDs, supplyChainData.ID)
+	}
+
+	if len(archivedIDs) > 0 {
+		// Fabric keeps only the last event set in a transaction, so a single event lists every archived ID
+		err = emitEvent(ctx, "RetentionEnforced", struct {
+			OrganizationID string   `json:"organizationId"`
+			IDs            []string `json:"ids"`
+		}{organizationID, archivedIDs})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(archivedIDs), nil
+}
+
+// getRetentionPolicy returns the retention policy an organization set for a data type, or nil if none is set
+func getRetentionPolicy(ctx contractapi.TransactionContextInterface, organizationID, dataType string) (*RetentionPolicy, error) {
+	retentionPolicyJSON, err := ctx.GetStub().GetState(retentionPolicyKey(organizationID, dataType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if retentionPolicyJSON == nil {
+		return nil, nil
+	}
+
+	var retentionPolicy RetentionPolicy
+	err = json.Unmarshal(retentionPolicyJSON, &retentionPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &retentionPolicy, nil
+}
+
+// retentionPolicyKey returns the ledger key of an organization's retention policy for a data type, honoring
+// caseInsensitiveOrgIDs
+func retentionPolicyKey(organizationID, dataType string) string {
+	if caseInsensitiveOrgIDs {
+		organizationID = strings.ToUpper(organizationID)
+	}
+	return fmt.Sprintf("%s%s_%s", retentionPolicyKeyPrefix, organizationID, dataType)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// revocationQuorum is the number of distinct organizations listed on a data point, counting the owner, that must
+// approve before an organization's access is revoked. When fewer organizations are listed, all of them must approve.
+const revocationQuorum = 2
+
+// RevocationApproval is a pending revocation of an organization's access to supply chain data
+type RevocationApproval struct {
+	DataID     string    `json:"dataId"`
+	RevokedOrg string    `json:"revokedOrg"` // Organization whose access is being revoked
+	ProposedBy string    `json:"proposedBy"` // Owner that proposed the revocation
+	Approvals  []string  `json:"approvals"`  // Organizations that approved the revocation, including the proposer
+	ProposedAt time.Time `json:"proposedAt"`
+}
+
+// ApproveRevocation adds the client organization's approval to the pending revocation of an organization's access
+// to supply chain data. Any organization that can access the data may approve, once.
+func (s *SmartContract) ApproveRevocation(ctx contractapi.TransactionContextInterface, dataID, orgToRevoke string) error {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, dataID, false)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	revocation, err := getRevocationApproval(ctx, dataID, orgToRevoke)
+	if err != nil {
+		return err
+	}
+	if revocation == nil {
+		return fmt.Errorf("%w: there is no pending revocation of organization %s for the supply chain data %s", ErrNotFound, orgToRevoke, dataID)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Each organization's approval is only counted once
+	if containsOrg(revocation.Approvals, clientOrgID) {
+		return fmt.Errorf("%w: organization %s has already approved the revocation of organization %s for the supply chain data %s", ErrConflict, clientOrgID, orgToRevoke, dataID)
+	}
+	revocation.Approvals = append(revocation.Approvals, clientOrgID)
+
+	return applyRevocationApprovals(ctx, supplyChainData, revocation, "ApproveRevocation", "AccessRevocationApproved")
+}
+
+// applyRevocationApprovals revokes the organization's access if the approvals reach the quorum, or saves the pending
+// revocation otherwise. It then records the operation in the audit trail and emits an AccessRevoked event, or
+// eventName if the quorum has not been reached yet.
+func applyRevocationApprovals(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData, revocation *RevocationApproval, operation, eventName string) error {
+	// The quorum cannot exceed the number of organizations able to approve
+	quorum := revocationQuorum
+	if parties := len(removeOrg(supplyChainData.AccessControl, supplyChainData.OrganizationID)) + 1; parties < quorum {
+		quorum = parties
+	}
+
+	revocationKey := revocationApprovalKey(revocation.DataID, revocation.RevokedOrg)
+	revoked := len(revocation.Approvals) >= quorum
+	if revoked {
+		// Revoke access
+		supplyChainData.AccessControl = removeOrg(supplyChainData.AccessControl, revocation.RevokedOrg)
+
+		// Put the data back on the ledger
+		err := putSupplyChainData(ctx, supplyChainData)
+		if err != nil {
+			return err
+		}
+
+		// The revocation has been handled
+		err = ctx.GetStub().DelState(revocationKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		eventName = "AccessRevoked"
+	} else {
+		revocationJSON, err := json.Marshal(revocation)
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PutState(revocationKey, revocationJSON)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := writeAuditEntry(ctx, operation, supplyChainData.ID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so the revoked organization stops attempting reads once revoked, and the other organizations
+	// know the state of the revocation until then
+	return emitEvent(ctx, eventName, struct {
+		DataID     string   `json:"dataId"`
+		RevokedOrg string   `json:"revokedOrg"`
+		Approvals  []string `json:"approvals"`
+		Quorum     int      `json:"quorum"`
+		Revoked    bool     `json:"revoked"`
+	}{revocation.DataID, revocation.RevokedOrg, revocation.Approvals, quorum, revoked})
+}
+
+// getRevocationApproval returns the pending revocation of an organization's access to supply chain data, or nil if
+// there is none
+func getRevocationApproval(ctx contractapi.TransactionContextInterface, dataID, revokedOrg string) (*RevocationApproval, error) {
+	revocationJSON, err := ctx.GetStub().GetState(revocationApprovalKey(dataID, revokedOrg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if revocationJSON == nil {
+		return nil, nil
+	}
+
+	var revocation RevocationApproval
+	err = json.Unmarshal(revocationJSON, &revocation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revocation, nil
+}
+
+// Helper function to get the ledger key of a pending revocation, honoring caseInsensitiveOrgIDs
+func revocationApprovalKey(dataID, revokedOrg string) string {
+	if caseInsensitiveOrgIDs {
+		revokedOrg = strings.ToUpper(revokedOrg)
+	}
+	return fmt.Sprintf("%s%s_%s", revocationKeyPrefix, dataID, revokedOrg)
+}
+
+// checkNoAccessRemoved returns a CONFLICT error if accessControl leaves out an organization the supply chain data is
+// shared with. Access may only be removed through RevokeDataAccess, so the other organizations get to approve.
+func checkNoAccessRemoved(supplyChainData *SupplyChainData, accessControl []string) error {
+	for _, org := range supplyChainData.AccessControl {
+		if !containsOrg(accessControl, org) {
+			return fmt.Errorf("%w: organization %s cannot be removed from the access control list of the supply chain data %s, use RevokeDataAccess", ErrConflict, org, supplyChainData.ID)
+		}
+	}
+	return nil
+}
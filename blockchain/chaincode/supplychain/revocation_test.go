@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// accessControl returns the access control list of data1 as the owner sees it
+func accessControl(t *testing.T, stub *testStub) []string {
+	t.Helper()
+	data, err := new(SmartContract).ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	return data.AccessControl
+}
+
+func TestOwnerCannotRemoveAccessDirectly(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+
+	mustFailWith(t, s.UpdateAccessControl(stub.as("Org1MSP"), "data1", []string{"Org3MSP"}), ErrConflict)
+	mustFailWith(t, s.PatchSupplyChainData(stub.as("Org1MSP"), "data1", `{"accessControl":[]}`), ErrConflict)
+	if !containsOrg(accessControl(t, stub), "Org2MSP") {
+		t.Fatal("the owner removed Org2MSP without the revocation quorum")
+	}
+
+	// Adding organizations is still allowed
+	mustSucceed(t, s.UpdateAccessControl(stub.as("Org1MSP"), "data1", []string{"Org2MSP", "Org3MSP"}))
+	mustSucceed(t, s.PatchSupplyChainData(stub.as("Org1MSP"), "data1", `{"accessControl":["Org2MSP","Org3MSP","Org4MSP"]}`))
+	if got := accessControl(t, stub); len(got) != 3 {
+		t.Fatalf("expected three organizations, got %v", got)
+	}
+}
+
+func TestRevocationNeedsQuorum(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP", "Org3MSP")
+
+	mustSucceed(t, s.RevokeDataAccess(stub.as("Org1MSP"), "data1", "Org2MSP"))
+	if !containsOrg(accessControl(t, stub), "Org2MSP") {
+		t.Fatal("the owner revoked Org2MSP alone")
+	}
+
+	mustSucceed(t, s.ApproveRevocation(stub.as("Org3MSP"), "data1", "Org2MSP"))
+	if containsOrg(accessControl(t, stub), "Org2MSP") {
+		t.Fatal("Org2MSP was not revoked once the quorum approved")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Severity categories of detected anomalies
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Lowest anomaly scores of each severity above low, shared by every record so categories are comparable
+const (
+	severityMediumScore   = 0.6
+	severityHighScore     = 0.75
+	severityCriticalScore = 0.9
+)
+
+// QueryAnomaliesBySeverity returns the supply chain data the client can access with a detected anomaly of the given
+// severity
+func (s *SmartContract) QueryAnomaliesBySeverity(ctx contractapi.TransactionContextInterface, severity string) ([]*SupplyChainData, error) {
+	switch severity {
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+	default:
+		return nil, fmt.Errorf("%w: invalid severity %q, must be one of %s, %s, %s or %s", ErrInvalidArgument, severity, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data with anomalies of the given severity
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"anomalyDetected": true,
+			"severity":        severity,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, filterByAccess(clientOrgID, results))
+}
+
+// severityForScore returns the severity category of a detected anomaly's score
+func severityForScore(anomalyScore float64) string {
+	switch {
+	case anomalyScore >= severityCriticalScore:
+		return SeverityCritical
+	case anomalyScore >= severityHighScore:
+		return SeverityHigh
+	case anomalyScore >= severityMediumScore:
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
@@ -1,14 +1,109 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// caseInsensitiveOrgIDs makes MSP ID comparisons ignore case, so "org1msp" and "Org1MSP" name the same organization.
+// Normalization happens at both ends: on write, owners are stored with the MSP ID from the client's certificate and
+// access lists are trimmed and de-duplicated; on read, every organization comparison goes through sameOrg.
+const caseInsensitiveOrgIDs = true
+
+// currentSchemaVersion is the SchemaVersion of newly created supply chain data
+const currentSchemaVersion = 2
+
+// Encryption schemes recorded in EncryptionScheme
+const (
+	EncryptionSchemeFernet = "fernet" // Fernet (AES-128-CBC with HMAC-SHA256), as used by the privacy layer
+	EncryptionSchemeNone   = "none"   // Plain JSON, stored by CreateSupplyChainDataSimple
+)
+
+// hideInaccessibleData makes reads of supply chain data the client cannot access fail with the same NOT_FOUND error
+// as reads of data that does not exist, so IDs owned by other organizations cannot be enumerated. Turn it off to
+// get UNAUTHORIZED errors instead, which are easier to debug but reveal that the data exists.
+const hideInaccessibleData = false
+
+// maxExplanationLength is the maximum number of characters of an anomaly explanation
+const maxExplanationLength = 2000
+
+// maxGetAllResults is the maximum number of supply chain data points GetAllSupplyChainData returns. Larger results
+// risk exceeding the gRPC message size limit, so callers are told to use GetAllSupplyChainDataPaginated instead.
+// It is a variable so tests can lower it.
+var maxGetAllResults = 1000
+
+// falsePositiveNote is appended to the explanation of anomalies cleared by ClearAnomalies
+const falsePositiveNote = "cleared as false positive"
+
+// adminMSPID is the organization allowed to perform network-wide administrative operations
+const adminMSPID = "Org1MSP"
+
+// Data types accepted for supply chain data
+const (
+	DataTypeShipment      = "shipment"
+	DataTypeInventory     = "inventory"
+	DataTypeProduction    = "production"
+	DataTypeQuality       = "quality"
+	DataTypeTemperature   = "temperature"
+	DataTypeHumidity      = "humidity"
+	DataTypeLocation      = "location"
+	DataTypeEnvironmental = "environmental"
+	DataTypeSupplyChain   = "supply_chain" // Generic type used by CreateSupplyChainDataSimple
+)
+
+// validDataTypes lists every accepted data type
+var validDataTypes = []string{
+	DataTypeShipment,
+	DataTypeInventory,
+	DataTypeProduction,
+	DataTypeQuality,
+	DataTypeTemperature,
+	DataTypeHumidity,
+	DataTypeLocation,
+	DataTypeEnvironmental,
+	DataTypeSupplyChain,
+}
+
+// Private data collection holding the encrypted payloads of data created with CreateSupplyChainDataPrivate.
+// It must match the name in collections_config.json.
+const privateDataCollection = "supplyChainPrivateData"
+
+// Transient map field carrying the encrypted payload for CreateSupplyChainDataPrivate
+const encryptedDataTransientKey = "encryptedData"
+
+// Key prefixes of ledger entries that are not supply chain data
+const (
+	dataTypeIndex                = "type~id" // Composite key index of supply chain data by data type
+	policyKeyPrefix              = "POLICY_"
+	mandatoryAccessKeyPrefix     = "MANDATORY_ACCESS_"
+	accessRequestKeyPrefix       = "REQUEST_"
+	organizationKeyPrefix        = "ORG_"
+	auditKeyPrefix               = "AUDIT_"
+	anomalyPolicyKeyPrefix       = "ANOMALY_POLICY_"
+	idempotencyKeyPrefix         = "IDEMPOTENT_"
+	retentionPolicyKeyPrefix     = "RETENTION_"
+	nonceKeyPrefix               = "NONCE_"
+	revocationKeyPrefix          = "REVOCATION_"
+	defaultAccessKeyPrefix       = "DEFAULT_ACCESS_"
+	resolutionApproversKeyPrefix = "RESOLUTION_APPROVERS_"
+	eventCoalescingKeyPrefix     = "EVENT_COALESCING_"
+)
+
+// reservedKeyPrefixes lists the prefixes of every ledger key that does not hold supply chain data
+var reservedKeyPrefixes = []string{policyKeyPrefix, mandatoryAccessKeyPrefix, accessRequestKeyPrefix, organizationKeyPrefix, auditKeyPrefix, anomalyPolicyKeyPrefix, idempotencyKeyPrefix, retentionPolicyKeyPrefix, nonceKeyPrefix, revocationKeyPrefix, defaultAccessKeyPrefix, resolutionApproversKeyPrefix, eventCoalescingKeyPrefix}
+
 // SmartContract provides functions for managing supply chain data
 type SmartContract struct {
 	contractapi.Contract
@@ -18,14 +113,63 @@ type SmartContract struct {
 type SupplyChainData struct {
 	ID              string    `json:"id"`
 	OrganizationID  string    `json:"organizationId"`
-	Timestamp       time.Time `json:"timestamp"`
-	EncryptedData   string    `json:"encryptedData"`   // Encrypted supply chain data
-	DataHash        string    `json:"dataHash"`        // Hash of the original data for integrity verification
-	DataType        string    `json:"dataType"`        // Type of supply chain data (e.g., shipment, inventory, production)
-	AccessControl   []string  `json:"accessControl"`   // List of organizations that can access this data
-	AnomalyDetected bool      `json:"anomalyDetected"` // Flag indicating if an anomaly was detected
-	AnomalyScore    float64   `json:"anomalyScore"`    // Score indicating the severity of the anomaly
-	Explanation     string    `json:"explanation"`     // Explanation of the anomaly (if detected)
+	Timestamp       time.Time `json:"timestamp"`                 // Creation time
+	LastModified    time.Time `json:"lastModified"`              // Time of the last change to the data
+	EncryptedData   string    `json:"encryptedData"`             // Encrypted supply chain data
+	DataHash        string    `json:"dataHash"`                  // Hash of the original data for integrity verification
+	DataType        string    `json:"dataType"`                  // Type of supply chain data (e.g., shipment, inventory, production)
+	AccessControl   []string  `json:"accessControl"`             // List of organizations that can access this data
+	AnomalyDetected bool      `json:"anomalyDetected"`           // Flag indicating if an anomaly was detected
+	AnomalyScore    float64   `json:"anomalyScore"`              // Score indicating the severity of the anomaly
+	Explanation     string    `json:"explanation"`               // Explanation of the anomaly (if detected)
+	Severity        string    `json:"severity,omitempty"`        // Category of the detected anomaly's score; empty if none is detected
+	AnomalyMetadata string    `json:"anomalyMetadata,omitempty"` // Structured model output, e.g. feature attributions, as a JSON document (a string so the contract metadata does not describe it as bytes)
+	Archived        bool      `json:"archived"`                  // Archived data is kept for audits but excluded from normal queries
+	ParentIDs       []string  `json:"parentIds,omitempty"`       // Supply chain data this data point was derived from
+	Tags            []string  `json:"tags,omitempty"`            // Free-form labels for grouping, e.g. "cold-chain"
+
+	AttributeAccessControl []string  `json:"attributeAccessControl,omitempty"` // Client attributes, as name=value, that grant read access regardless of organization
+	ExpiresAt              time.Time `json:"expiresAt"`                        // Time after which the data is excluded from reads and queries; zero if it never expires
+	Version                int       `json:"version"`                          // Incremented on every write, used to detect concurrent updates
+	SchemaVersion          int       `json:"schemaVersion"`                    // Version of the record layout; 1 for records created before it was tracked
+	EncryptionScheme       string    `json:"encryptionScheme"`                 // Scheme that produced EncryptedData; empty for schema version 1 records
+	KeyRotations           int       `json:"keyRotations,omitempty"`           // Times EncryptedData was re-encrypted with RotateEncryptionKey
+	RotatedAt              time.Time `json:"rotatedAt"`                        // Time of the last key rotation; zero if the key was never rotated
+	Chunked                bool      `json:"chunked,omitempty"`                // EncryptedData holds several separately encrypted chunks
+	Compressed             bool      `json:"compressed,omitempty"`             // The payload was compressed before it was encrypted
+	PrivateDataHash        string    `json:"privateDataHash,omitempty"`        // Hex-encoded SHA-256 digest of the payload kept in the private data collection, if any
+
+	ResolutionProposedBy string   `json:"resolutionProposedBy,omitempty"` // Organization that proposed clearing the anomaly
+	ResolutionApprovals  []string `json:"resolutionApprovals,omitempty"`  // Organizations that approved clearing the anomaly
+
+	OwnershipHistory []OwnershipRecord `json:"ownershipHistory,omitempty"` // Every owner in order, recorded from the first transfer on
+
+	Disputed      bool   `json:"disputed"`                // Disputed data cannot be changed until the owner resolves the dispute
+	DisputeReason string `json:"disputeReason,omitempty"` // Why an organization disputed the data
+
+	RedactedFields []string `json:"redactedFields,omitempty"` // JSON names of fields blanked when organizations other than the owner read the data
+}
+
+// OwnershipRecord is one owner in the ownership history of a supply chain data point
+type OwnershipRecord struct {
+	OrganizationID string    `json:"organizationId"`
+	AcquiredAt     time.Time `json:"acquiredAt"` // Creation time for the original owner, transfer time for later owners
+}
+
+// UnmarshalJSON decodes supply chain data, defaulting the fields that records written before they were added lack
+func (d *SupplyChainData) UnmarshalJSON(data []byte) error {
+	// The alias type has the same fields but not this method, so decoding it does not recurse
+	type supplyChainDataFields SupplyChainData
+	err := json.Unmarshal(data, (*supplyChainDataFields)(d))
+	if err != nil {
+		return err
+	}
+
+	if d.SchemaVersion == 0 {
+		d.SchemaVersion = 1
+	}
+
+	return nil
 }
 
 // AccessPolicy defines who can access what data
@@ -38,39 +182,489 @@ type AccessPolicy struct {
 	UpdatedAt      time.Time `json:"updatedAt"`
 }
 
+// supplyChainDataInput is a supply chain data point as submitted by a client in a batch
+type supplyChainDataInput struct {
+	ID             string   `json:"id"`
+	OrganizationID string   `json:"organizationId"`
+	EncryptedData  string   `json:"encryptedData"`
+	DataHash       string   `json:"dataHash"`
+	DataType       string   `json:"dataType"`
+	AccessControl  []string `json:"accessControl"`
+}
+
+// anomalyUpdate is one anomaly status update as submitted by the anomaly detection service in a batch
+type anomalyUpdate struct {
+	ID              string          `json:"id"`
+	AnomalyDetected bool            `json:"anomalyDetected"`
+	AnomalyScore    float64         `json:"anomalyScore"`
+	Explanation     string          `json:"explanation"`
+	AnomalyMetadata json.RawMessage `json:"anomalyMetadata,omitempty"`
+	ExpectedVersion int             `json:"expectedVersion"`
+}
+
+// supplyChainDataFilter is the filter of QuerySupplyChainData. Every field is optional, and present fields are
+// combined with AND.
+type supplyChainDataFilter struct {
+	OrganizationID  string `json:"organizationId"`
+	DataType        string `json:"dataType"`
+	AnomalyDetected *bool  `json:"anomalyDetected"`
+	StartTime       string `json:"startTime"` // RFC 3339, inclusive
+	EndTime         string `json:"endTime"`   // RFC 3339, inclusive
+}
+
+// BatchUpdateResult reports the outcome of a batch update
+type BatchUpdateResult struct {
+	Updated  int            `json:"updated"`  // Number of updates that were applied
+	Failures []BatchFailure `json:"failures"` // Updates that could not be applied
+}
+
+// BatchFailure is an update in a batch that could not be applied
+type BatchFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// PaginatedQueryResult is one page of supply chain data along with the bookmark for the next page
+type PaginatedQueryResult struct {
+	Records             []*SupplyChainData `json:"records"`
+	FetchedRecordsCount int32              `json:"fetchedRecordsCount"`
+	Bookmark            string             `json:"bookmark"` // Empty when there are no more pages
+}
+
+// ValidationResult reports whether supply chain data would be accepted and, if not, why
+type ValidationResult struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason"` // Empty when the data is valid
+}
+
+// AccessSummary counts the supply chain data an organization owns and the data shared with it
+type AccessSummary struct {
+	OrganizationID string `json:"organizationId"`
+	OwnedCount     int    `json:"ownedCount"`
+	SharedCount    int    `json:"sharedCount"`
+}
+
+// SupplyChainDataMeta is supply chain data along with metadata computed when it is read
+type SupplyChainDataMeta struct {
+	Data       *SupplyChainData `json:"data"`
+	AgeSeconds int64            `json:"ageSeconds"` // Seconds from the data's creation to the reading transaction
+}
+
+// HistoryEntry is one version of a supply chain data point as recorded in the key's history
+type HistoryEntry struct {
+	TxID      string           `json:"txId"`
+	Timestamp time.Time        `json:"timestamp"`
+	IsDelete  bool             `json:"isDelete"`
+	Value     *SupplyChainData `json:"value,omitempty"` // Not set for delete entries
+}
+
+// MandatoryAccess names an organization that must always be able to access a type of data
+type MandatoryAccess struct {
+	DataType    string    `json:"dataType"`
+	RequiredOrg string    `json:"requiredOrg"` // Organization always included in AccessControl for this data type
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// WriteReceipt is an acknowledgment of a committed write of a supply chain data point. It carries no signature:
+// VerifyReceipt checks every field against the ledger history instead.
+type WriteReceipt struct {
+	ID           string    `json:"id"`
+	DataHash     string    `json:"dataHash"`     // DataHash written
+	Timestamp    time.Time `json:"timestamp"`    // Timestamp written
+	LastModified time.Time `json:"lastModified"` // LastModified written
+	Version      int       `json:"version"`      // Version written
+	TxID         string    `json:"txId"`         // Transaction that made the write
+	TxTimestamp  time.Time `json:"txTimestamp"`  // Timestamp of the transaction that made the write
+}
+
+// AnomalyStats summarizes the anomalies detected in an organization's supply chain data
+type AnomalyStats struct {
+	OrganizationID      string  `json:"organizationId"`
+	TotalRecords        int     `json:"totalRecords"`
+	AnomalyCount        int     `json:"anomalyCount"`
+	AverageAnomalyScore float64 `json:"averageAnomalyScore"` // Average over anomalous records only
+	MaxAnomalyScore     float64 `json:"maxAnomalyScore"`
+}
+
+// TamperCheck is the outcome of one category of a tamper-evidence report
+type TamperCheck struct {
+	Passed       bool     `json:"passed"`
+	OffendingIDs []string `json:"offendingIds"` // Keys of the data points that failed the check
+}
+
+// TamperReport collects the tamper-evidence checks run across an organization's supply chain data
+type TamperReport struct {
+	OrganizationID        string      `json:"organizationId"`
+	GeneratedAt           time.Time   `json:"generatedAt"`
+	RecordCount           int         `json:"recordCount"`
+	HashConsistency       TamperCheck `json:"hashConsistency"`       // DataHash is a well-formed SHA-256 hex digest
+	KeyConsistency        TamperCheck `json:"keyConsistency"`        // Record ID matches the key it is stored under
+	OwnershipConsistency  TamperCheck `json:"ownershipConsistency"`  // OrganizationID matches the latest owner in OwnershipHistory
+	TimestampPlausibility TamperCheck `json:"timestampPlausibility"` // Timestamp is set and not later than the report transaction
+	DanglingReferences    TamperCheck `json:"danglingReferences"`    // Every ParentIDs entry names supply chain data on the ledger
+	Passed                bool        `json:"passed"`
+}
+
 // InitLedger adds a base set of supply chain data to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	// No initial data needed
 	return nil
 }
 
-// CreateSupplyChainData adds a new supply chain data point to the ledger
-func (s *SmartContract) CreateSupplyChainData(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl []string) error {
-	// Check if the data already exists
-	exists, err := s.SupplyChainDataExists(ctx, id)
+// CreateSupplyChainData adds a new supply chain data point to the ledger and returns its ID. An empty
+// accessControl falls back to the owner's default set with SetDefaultAccessList.
+// If idempotencyKey is not empty and the client's organization already created a data point with the same key and
+// ID, that ID is returned and nothing is written, so clients can safely retry a create whose outcome they did not
+// see. Reusing a key for a different ID returns a CONFLICT error. Keys are scoped to the client's organization.
+func (s *SmartContract) CreateSupplyChainData(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl []string, idempotencyKey string) (string, error) {
+	// Check if this create has already been submitted
+	var idempotencyLedgerKey string
+	if idempotencyKey != "" {
+		clientOrgID, err := getClientOrgID(ctx)
+		if err != nil {
+			return "", err
+		}
+		idempotencyLedgerKey = idempotencyKeyFor(clientOrgID, idempotencyKey)
+
+		existingIDBytes, err := ctx.GetStub().GetState(idempotencyLedgerKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if existingIDBytes != nil {
+			if string(existingIDBytes) != id {
+				return "", fmt.Errorf("%w: idempotency key %q was already used to create the supply chain data %s", ErrConflict, idempotencyKey, existingIDBytes)
+			}
+			return id, nil
+		}
+	}
+
+	// Validate and build the supply chain data object
+	supplyChainData, err := s.newSupplyChainData(ctx, id, organizationID, encryptedData, dataHash, dataType, accessControl)
+	if err != nil {
+		return "", err
+	}
+
+	// Put the data and its index entries on the ledger
+	err = putNewSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return "", err
+	}
+
+	// Remember the ID under the idempotency key so a retry returns it
+	if idempotencyKey != "" {
+		err = ctx.GetStub().PutState(idempotencyLedgerKey, []byte(id))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return id, nil
+}
+
+// idempotencyKeyFor returns the ledger key of an organization's idempotency key, honoring caseInsensitiveOrgIDs, so
+// organizations cannot see or collide with each other's keys
+func idempotencyKeyFor(organizationID, idempotencyKey string) string {
+	if caseInsensitiveOrgIDs {
+		organizationID = strings.ToUpper(organizationID)
+	}
+	return fmt.Sprintf("%s%s_%s", idempotencyKeyPrefix, organizationID, idempotencyKey)
+}
+
+// GenerateDataID returns a new supply chain data ID derived from the organization, the data type and the ID of the
+// current transaction. Every peer derives the same ID within a transaction, and different transactions always get
+// different IDs, so clients can get a unique ID without generating one themselves.
+func (s *SmartContract) GenerateDataID(ctx contractapi.TransactionContextInterface, organizationID, dataType string) (string, error) {
+	if organizationID == "" {
+		return "", fmt.Errorf("%w: organization must not be empty", ErrInvalidArgument)
+	}
+	err := ValidateDataType(dataType)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256([]byte(strings.Join([]string{organizationID, dataType, ctx.GetStub().GetTxID()}, "|")))
+	return fmt.Sprintf("%s_%s", dataType, hex.EncodeToString(digest[:16])), nil
+}
+
+// CreateSupplyChainDataWithResult adds a new supply chain data point like CreateSupplyChainData and returns the
+// data as written, including the timestamps assigned from the transaction, so no follow-up read is needed
+func (s *SmartContract) CreateSupplyChainDataWithResult(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl []string) (*SupplyChainData, error) {
+	// Validate and build the supply chain data object
+	supplyChainData, err := s.newSupplyChainData(ctx, id, organizationID, encryptedData, dataHash, dataType, accessControl)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put the data and its index entries on the ledger
+	err = putNewSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	return supplyChainData, nil
+}
+
+// CreateSupplyChainDataWithExpiry adds a new supply chain data point that expires at expiresAtRFC3339.
+// Once expired, the data is no longer returned by reads and queries but stays on the ledger.
+func (s *SmartContract) CreateSupplyChainDataWithExpiry(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl []string, expiresAtRFC3339 string) error {
+	expiresAt, err := time.Parse(time.RFC3339, expiresAtRFC3339)
+	if err != nil {
+		return fmt.Errorf("%w: invalid expiry time %q, expected RFC 3339: %v", ErrInvalidArgument, expiresAtRFC3339, err)
+	}
+
+	// Compare against the transaction timestamp so every endorsing peer reaches the same result
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if !expiresAt.After(now) {
+		return fmt.Errorf("%w: expiry time %s is not in the future", ErrInvalidArgument, expiresAtRFC3339)
+	}
+
+	// Validate and build the supply chain data object
+	supplyChainData, err := s.newSupplyChainData(ctx, id, organizationID, encryptedData, dataHash, dataType, accessControl)
+	if err != nil {
+		return err
+	}
+	supplyChainData.ExpiresAt = expiresAt
+
+	// Put the data and its index entries on the ledger
+	return putNewSupplyChainData(ctx, supplyChainData)
+}
+
+// CreateSupplyChainDataBatch adds several supply chain data points in one transaction. recordsJSON is a JSON array of
+// objects with the same fields as CreateSupplyChainData takes. Every record is validated before any is written,
+// so either the whole batch is committed or none of it is.
+func (s *SmartContract) CreateSupplyChainDataBatch(ctx contractapi.TransactionContextInterface, recordsJSON string) error {
+	// Parse the records
+	var inputs []supplyChainDataInput
+	err := json.Unmarshal([]byte(recordsJSON), &inputs)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse records JSON: %v", ErrInvalidArgument, err)
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("%w: the batch does not contain any records", ErrInvalidArgument)
+	}
+
+	// Validate and build every record before writing anything
+	records := make([]*SupplyChainData, len(inputs))
+	seen := make(map[string]int, len(inputs))
+	for i, input := range inputs {
+		if first, ok := seen[input.ID]; ok {
+			return fmt.Errorf("%w: id %s duplicates record %d (record %d)", ErrInvalidArgument, input.ID, first, i)
+		}
+		seen[input.ID] = i
+
+		records[i], err = s.newSupplyChainData(ctx, input.ID, input.OrganizationID, input.EncryptedData, input.DataHash, input.DataType, input.AccessControl)
+		if err != nil {
+			return fmt.Errorf("%w (record %d)", err, i)
+		}
+	}
+
+	// Put the data and its index entries on the ledger
+	for i, record := range records {
+		err = putNewSupplyChainData(ctx, record)
+		if err != nil {
+			return fmt.Errorf("%w (record %d)", err, i)
+		}
+	}
+
+	return nil
+}
+
+// CreateSupplyChainDataPrivate adds a new supply chain data point whose encrypted payload is kept in a private data
+// collection. The payload is read from the "encryptedData" transient field so it never reaches the channel ledger;
+// only the metadata and DataHash are stored publicly.
+func (s *SmartContract) CreateSupplyChainDataPrivate(ctx contractapi.TransactionContextInterface, id, organizationID, dataHash, dataType string, accessControl []string) error {
+	// Get the encrypted payload from the transient map
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	encryptedData, ok := transientMap[encryptedDataTransientKey]
+	if !ok || len(encryptedData) == 0 {
+		return fmt.Errorf("%w: the %s field must be provided in the transient map", ErrInvalidArgument, encryptedDataTransientKey)
+	}
+
+	// Validate and build the public supply chain data object without the payload
+	supplyChainData, err := s.newSupplyChainData(ctx, id, organizationID, "", dataHash, dataType, accessControl)
 	if err != nil {
 		return err
 	}
+
+	// Put the payload in the private data collection, publishing its digest so organizations outside the
+	// collection can verify it with VerifyPrivateDataHash
+	err = ctx.GetStub().PutPrivateData(privateDataCollection, id, encryptedData)
+	if err != nil {
+		return fmt.Errorf("failed to put private data: %v", err)
+	}
+	privateDataDigest := sha256.Sum256(encryptedData)
+	supplyChainData.PrivateDataHash = hex.EncodeToString(privateDataDigest[:])
+
+	// Put the public data and its index entries on the ledger
+	return putNewSupplyChainData(ctx, supplyChainData)
+}
+
+// ReadPrivateData returns the encrypted payload of a supply chain data point from the private data collection. The
+// payload is withheld, with an UNAUTHORIZED error, from organizations the owner redacted encryptedData for.
+func (s *SmartContract) ReadPrivateData(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	// Check the client can access the public supply chain data
+	supplyChainData, err := readSupplyChainData(ctx, id, true)
+	if err != nil {
+		return "", err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The private payload is the encrypted data, so it is redacted along with it
+	if !sameOrg(clientOrgID, supplyChainData.OrganizationID) && contains(supplyChainData.RedactedFields, "encryptedData") {
+		return "", fmt.Errorf("%w: the owner of the supply chain data %s does not share its payload with organization %s", ErrUnauthorized, id, clientOrgID)
+	}
+
+	encryptedData, err := ctx.GetStub().GetPrivateData(privateDataCollection, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read private data: %v", err)
+	}
+	if encryptedData == nil {
+		return "", fmt.Errorf("%w: the private data for %s does not exist", ErrNotFound, id)
+	}
+
+	return string(encryptedData), nil
+}
+
+// ValidateSupplyChainData runs the same checks as CreateSupplyChainData without writing anything, so clients can
+// find out whether data would be accepted before submitting it. Rejections are reported in the result; an error is
+// only returned if the checks themselves could not be run.
+func (s *SmartContract) ValidateSupplyChainData(ctx contractapi.TransactionContextInterface, id, organizationID, dataHash, dataType string, accessControl []string) (*ValidationResult, error) {
+	_, err := s.newSupplyChainData(ctx, id, organizationID, "", dataHash, dataType, accessControl)
+	if errors.Is(err, ErrInvalidArgument) || errors.Is(err, ErrAlreadyExists) || errors.Is(err, ErrUnauthorized) {
+		return &ValidationResult{Valid: false, Reason: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidationResult{Valid: true}, nil
+}
+
+// VerifyPrivateDataHash checks that the payload in the private data collection still matches the PrivateDataHash
+// published when the data was created. It compares against the hash the peer keeps of the private data, so
+// organizations that are not members of the collection can verify the payload without being able to read it.
+//
+// DataHash cannot be used for this, as it is the hash of the plaintext while the collection holds the encrypted
+// payload.
+func (s *SmartContract) VerifyPrivateDataHash(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if supplyChainData.PrivateDataHash == "" {
+		return false, fmt.Errorf("%w: the supply chain data %s has no private data hash", ErrNotFound, id)
+	}
+
+	privateDataHash, err := ctx.GetStub().GetPrivateDataHash(privateDataCollection, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to read private data hash: %v", err)
+	}
+	if privateDataHash == nil {
+		return false, fmt.Errorf("%w: the private data for %s does not exist", ErrNotFound, id)
+	}
+
+	return hex.EncodeToString(privateDataHash) == supplyChainData.PrivateDataHash, nil
+}
+
+// newSupplyChainData runs the checks required to create supply chain data and builds the object without writing it
+func (s *SmartContract) newSupplyChainData(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl []string) (*SupplyChainData, error) {
+	if id == "" || !isSupplyChainDataKey(id) {
+		return nil, fmt.Errorf("%w: invalid supply chain data id %q", ErrInvalidArgument, id)
+	}
+
+	// Check if the data already exists
+	exists, err := s.supplyChainDataExists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	if exists {
-		return fmt.Errorf("the supply chain data %s already exists", id)
+		return nil, fmt.Errorf("%w: the supply chain data %s already exists", ErrAlreadyExists, id)
 	}
 
 	// Get the identity of the client submitting the transaction
 	clientOrgID, err := getClientOrgID(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Verify that the client belongs to the organization they claim to represent
-	if clientOrgID != organizationID {
-		return fmt.Errorf("client from organization %s cannot create data for organization %s", clientOrgID, organizationID)
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s cannot create data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	for _, org := range accessControl {
+		if strings.TrimSpace(org) == "" {
+			return nil, fmt.Errorf("%w: access control list must not contain an empty organization", ErrInvalidArgument)
+		}
+	}
+
+	// Store the owner as spelled in the client's certificate and tidy up the access list
+	organizationID = clientOrgID
+	accessControl = normalizeOrgIDs(accessControl)
+
+	// Fall back to the owner's default access list so data is not created unshared by accident
+	if len(accessControl) == 0 {
+		defaultAccessControl, err := getDefaultAccessList(ctx, organizationID)
+		if err != nil {
+			return nil, err
+		}
+		accessControl = append(accessControl, defaultAccessControl...)
+	}
+
+	// Catch organizations that are not part of the network
+	err = s.checkRegisteredOrgs(ctx, accessControl)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateDataType(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Integrity checks rely on every record carrying a real hash
+	if !isValidDataHash(dataHash) {
+		return nil, fmt.Errorf("%w: data hash must be a %d character hex-encoded SHA-256 digest", ErrInvalidArgument, sha256.Size*2)
+	}
+
+	// Share the data with every organization allowed by the owner's access policies for this data type.
+	// Organizations passed explicitly are kept, so the result is the union of both lists.
+	accessControl, err = withPolicyAccess(ctx, dataType, organizationID, accessControl)
+	if err != nil {
+		return nil, err
+	}
+
+	// Always share the data with the organization required for this data type, if any
+	accessControl, err = withMandatoryAccess(ctx, dataType, organizationID, accessControl)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the transaction timestamp so every endorsing peer writes the same value
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the supply chain data object
-	supplyChainData := SupplyChainData{
+	return &SupplyChainData{
 		ID:              id,
 		OrganizationID:  organizationID,
-		Timestamp:       time.Now(),
+		Timestamp:       now,
+		LastModified:    now,
 		EncryptedData:   encryptedData,
 		DataHash:        dataHash,
 		DataType:        dataType,
@@ -78,70 +672,2896 @@ func (s *SmartContract) CreateSupplyChainData(ctx contractapi.TransactionContext
 		AnomalyDetected: false,
 		AnomalyScore:    0.0,
 		Explanation:     "",
+
+		SchemaVersion:    currentSchemaVersion,
+		EncryptionScheme: EncryptionSchemeFernet,
+	}, nil
+}
+
+// UpdateAnomalyStatus updates the anomaly status of a supply chain data point. The anomaly is only flagged if
+// anomalyScore exceeds the threshold set for the data type with SetAnomalyThreshold, and a flagged anomaly is given a
+// Severity derived from its score. anomalyMetadataJSON carries the structured output of the detection model, such as
+// feature attributions, and may be empty. expectedVersion must be the Version the caller last read; if the data was
+// written since then, a CONFLICT error is returned instead of overwriting the other update, and the caller should
+// re-read the data and retry. A detected anomaly cannot be cleared with UpdateAnomalyStatus: a CONFLICT error is
+// returned, and the anomaly must be resolved with ProposeAnomalyResolution so the other organizations approve. Like
+// every write to the data, the update must be endorsed by a peer of the owner, also when a grantee reports it.
+func (s *SmartContract) UpdateAnomalyStatus(ctx contractapi.TransactionContextInterface, id string, anomalyDetected bool, anomalyScore float64, explanation, anomalyMetadataJSON string, expectedVersion int) error {
+	supplyChainData, err := updateAnomalyStatus(ctx, id, anomalyDetected, anomalyScore, explanation, anomalyMetadataJSON, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event if an anomaly was detected
+	if supplyChainData.AnomalyDetected {
+		return emitAnomalyDetected(ctx, supplyChainData)
+	}
+
+	return nil
+}
+
+// updateAnomalyStatus applies an anomaly status update like UpdateAnomalyStatus, without emitting an event, and
+// returns the updated supply chain data
+func updateAnomalyStatus(ctx contractapi.TransactionContextInterface, id string, anomalyDetected bool, anomalyScore float64, explanation, anomalyMetadataJSON string, expectedVersion int) (*SupplyChainData, error) {
+	// Validate the explanation before touching the ledger
+	explanation = strings.TrimSpace(explanation)
+	if !utf8.ValidString(explanation) {
+		return nil, fmt.Errorf("%w: explanation must be valid UTF-8", ErrInvalidArgument)
+	}
+	if length := utf8.RuneCountInString(explanation); length > maxExplanationLength {
+		return nil, fmt.Errorf("%w: explanation is %d characters long, the maximum is %d", ErrInvalidArgument, length, maxExplanationLength)
+	}
+	if anomalyMetadataJSON != "" && !json.Valid([]byte(anomalyMetadataJSON)) {
+		return nil, fmt.Errorf("%w: anomaly metadata must be valid JSON", ErrInvalidArgument)
+	}
+
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := readSupplyChainData(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to overwrite an update the caller has not seen
+	if supplyChainData.Version != expectedVersion {
+		return nil, fmt.Errorf("%w: the supply chain data %s is at version %d, expected version %d", ErrConflict, id, supplyChainData.Version, expectedVersion)
+	}
+
+	// Only flag an anomaly if the score exceeds the threshold for the data type, whichever client reported it
+	threshold, err := getAnomalyThreshold(ctx, supplyChainData.DataType)
+	if err != nil {
+		return nil, err
+	}
+	anomalyDetected = anomalyDetected && anomalyScore > threshold
+
+	// Clearing a detected anomaly needs the approval of the other organizations
+	if supplyChainData.AnomalyDetected && !anomalyDetected {
+		return nil, fmt.Errorf("%w: the supply chain data %s has a detected anomaly, which can only be cleared through ProposeAnomalyResolution", ErrConflict, id)
+	}
+
+	// Update the anomaly status
+	supplyChainData.AnomalyDetected = anomalyDetected
+	supplyChainData.AnomalyScore = anomalyScore
+	supplyChainData.Severity = ""
+	if anomalyDetected {
+		supplyChainData.Severity = severityForScore(anomalyScore)
+	}
+	supplyChainData.Explanation = explanation
+	supplyChainData.AnomalyMetadata = anomalyMetadataJSON
+
+	// A newly reported anomaly invalidates any pending resolution of the previous one
+	if anomalyDetected {
+		supplyChainData.ResolutionProposedBy = ""
+		supplyChainData.ResolutionApprovals = nil
+	}
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeAuditEntry(ctx, "UpdateAnomalyStatus", supplyChainData.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return supplyChainData, nil
+}
+
+// emitAnomalyDetected emits the AnomalyDetected event for supply chain data with a newly detected anomaly
+func emitAnomalyDetected(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) error {
+	var anomalyMetadata json.RawMessage
+	if supplyChainData.AnomalyMetadata != "" {
+		anomalyMetadata = json.RawMessage(supplyChainData.AnomalyMetadata)
+	}
+	return emitEvent(ctx, "AnomalyDetected", AnomalyDetectedEvent{
+		EventHeader:     EventHeader{EventVersion: anomalyDetectedEventVersion},
+		ID:              supplyChainData.ID,
+		OrganizationID:  supplyChainData.OrganizationID,
+		DataType:        supplyChainData.DataType,
+		AnomalyScore:    supplyChainData.AnomalyScore,
+		Severity:        supplyChainData.Severity,
+		Explanation:     supplyChainData.Explanation,
+		AnomalyMetadata: anomalyMetadata,
+	})
+}
+
+// ClearAnomalies marks the detected anomalies of several supply chain data points as false positives, e.g. after the
+// model was retrained. Clearing an anomaly needs the same approvals as ProposeAnomalyResolution, so for each data
+// point the owner's approval is added to its resolution, proposing one if none is pending. An anomaly whose approvals
+// reach the quorum and include every approver required with RequireApproversForResolution is cleared at once: the anomaly flag and score are reset and a note is appended to the explanation. The
+// others stay pending until the other organizations call ApproveAnomalyResolution. Only the owning organization can
+// clear anomalies, and either every listed data point is processed or none is. Data without a detected anomaly is
+// left alone. Fabric keeps only the last event set in a transaction, so a single AnomalyCleared event lists the IDs
+// of every cleared data point and of those still awaiting approval.
+func (s *SmartContract) ClearAnomalies(ctx contractapi.TransactionContextInterface, ids []string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: no supply chain data to clear", ErrInvalidArgument)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	cleared := []string{}
+	pending := []string{}
+	for _, id := range ids {
+		if contains(cleared, id) || contains(pending, id) {
+			continue
+		}
+
+		// Get the supply chain data, verifying the client owns it
+		supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !supplyChainData.AnomalyDetected {
+			continue
+		}
+		err = checkNotDisputed(supplyChainData)
+		if err != nil {
+			return err
+		}
+
+		// Add the owner's approval to the resolution, proposing one if none is pending
+		if supplyChainData.ResolutionProposedBy == "" {
+			supplyChainData.ResolutionProposedBy = clientOrgID
+			supplyChainData.ResolutionApprovals = nil
+		}
+		if !containsOrg(supplyChainData.ResolutionApprovals, clientOrgID) {
+			supplyChainData.ResolutionApprovals = append(supplyChainData.ResolutionApprovals, clientOrgID)
+		}
+
+		requiredApprovers, err := getRequiredApprovers(ctx, supplyChainData.OrganizationID)
+		if err != nil {
+			return err
+		}
+
+		// Clear the anomaly if the quorum and the required approvers approved, keeping the original explanation for
+		// reference
+		if _, _, resolved := resolveIfApproved(supplyChainData, requiredApprovers); resolved {
+			supplyChainData.AnomalyScore = 0
+			if supplyChainData.Explanation == "" {
+				supplyChainData.Explanation = falsePositiveNote
+			} else {
+				supplyChainData.Explanation += " (" + falsePositiveNote + ")"
+			}
+			cleared = append(cleared, id)
+		} else {
+			pending = append(pending, id)
+		}
+
+		// Put the data back on the ledger
+		err = putSupplyChainData(ctx, supplyChainData)
+		if err != nil {
+			return err
+		}
+
+		err = writeAuditEntry(ctx, "ClearAnomalies", id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Emit an event so alerting systems can retract their notifications and the other organizations can approve
+	return emitEvent(ctx, "AnomalyCleared", struct {
+		IDs     []string `json:"ids"`
+		Pending []string `json:"pending"` // Data points whose resolution still needs approvals
+	}{cleared, pending})
+}
+
+// UpdateAnomalyStatusBatch applies several anomaly status updates in one transaction. updatesJSON is a JSON array of
+// {id, anomalyDetected, anomalyScore, explanation, anomalyMetadata, expectedVersion} objects. An update that fails does not stop the others: the failures
+// are collected and reported in the result, and the transaction still commits every update that succeeded. Returning
+// an error would make Fabric discard the whole transaction, so an error is only returned for malformed input, such as
+// an ID listed twice, whose updates would overwrite each other and their audit entries. Fabric keeps only the last
+// event set in a transaction, so subscribers see one AnomalyDetected event per batch, for the last anomaly detected.
+// If the client's organization enabled coalescing with SetEventCoalescing, a single AnomalyBatchUpdated event
+// summarizing every update in the batch is emitted instead.
+func (s *SmartContract) UpdateAnomalyStatusBatch(ctx contractapi.TransactionContextInterface, updatesJSON string) (*BatchUpdateResult, error) {
+	// Parse the updates
+	var updates []anomalyUpdate
+	err := json.Unmarshal([]byte(updatesJSON), &updates)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse updates JSON: %v", ErrInvalidArgument, err)
+	}
+
+	// Reject duplicate IDs before applying anything
+	seen := make(map[string]int, len(updates))
+	for i, update := range updates {
+		if first, ok := seen[update.ID]; ok {
+			return nil, fmt.Errorf("%w: id %s duplicates update %d (update %d)", ErrInvalidArgument, update.ID, first, i)
+		}
+		seen[update.ID] = i
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	coalesce, err := isEventCoalescingEnabled(ctx, clientOrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply each update, collecting the failures
+	result := BatchUpdateResult{Failures: []BatchFailure{}}
+	summary := AnomalyBatchUpdatedEvent{
+		EventHeader:    EventHeader{EventVersion: anomalyBatchUpdatedEventVersion},
+		OrganizationID: clientOrgID,
+		IDs:            []string{},
+		SeverityCounts: map[string]int{},
+	}
+	for _, update := range updates {
+		supplyChainData, err := updateAnomalyStatus(ctx, update.ID, update.AnomalyDetected, update.AnomalyScore, update.Explanation, string(update.AnomalyMetadata), update.ExpectedVersion)
+		if err != nil {
+			result.Failures = append(result.Failures, BatchFailure{ID: update.ID, Error: err.Error()})
+			continue
+		}
+		result.Updated++
+
+		summary.IDs = append(summary.IDs, supplyChainData.ID)
+		if supplyChainData.AnomalyDetected {
+			summary.SeverityCounts[supplyChainData.Severity]++
+			if !coalesce {
+				err = emitAnomalyDetected(ctx, supplyChainData)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	summary.Failed = len(result.Failures)
+
+	if coalesce {
+		err = emitEvent(ctx, "AnomalyBatchUpdated", summary)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, nil
+}
+
+// ReadSupplyChainData returns the supply chain data stored in the ledger. Besides the owner and the organizations in
+// AccessControl, any client holding one of the attributes in AttributeAccessControl can read it. Fields the owner
+// redacted with SetRedactedFields are blanked for everyone but the owner.
+func (s *SmartContract) ReadSupplyChainData(ctx contractapi.TransactionContextInterface, id string) (*SupplyChainData, error) {
+	supplyChainData, err := readSupplyChainData(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hide the fields the owner redacted from other organizations
+	return redactForClient(clientOrgID, supplyChainData), nil
+}
+
+// readSupplyChainData gets supply chain data the client can access, treating expired data as missing. Attribute
+// based access only grants reads, so functions that go on to modify the data must pass allowAttributeAccess false.
+func readSupplyChainData(ctx contractapi.TransactionContextInterface, id string, allowAttributeAccess bool) (*SupplyChainData, error) {
+	// Get the supply chain data from the ledger
+	supplyChainData, err := getSupplyChainData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to access this data, falling back to the client's attributes
+	allowed := canAccess(clientOrgID, supplyChainData)
+	if !allowed && allowAttributeAccess {
+		allowed, err = hasAttributeAccess(ctx, supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !allowed {
+		return nil, readDeniedError(clientOrgID, id)
+	}
+
+	// Expired data is treated as gone
+	expired, err := isExpired(ctx, supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, fmt.Errorf("%w: the supply chain data %s has expired", ErrNotFound, id)
+	}
+
+	return supplyChainData, nil
+}
+
+// ReadSupplyChainDataBatch returns the supply chain data for several IDs in one call, in the order requested.
+// IDs that do not exist, have expired or that the client is not authorized to read are silently skipped rather
+// than failing the whole call, so the result may be shorter than ids; compare the returned IDs to find them.
+// Duplicate IDs are returned once.
+func (s *SmartContract) ReadSupplyChainDataBatch(ctx contractapi.TransactionContextInterface, ids []string) ([]*SupplyChainData, error) {
+	results := []*SupplyChainData{}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		// Read the data, enforcing access control
+		supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnauthorized) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, supplyChainData)
+	}
+
+	return results, nil
+}
+
+// ReadSupplyChainDataWithMeta returns supply chain data like ReadSupplyChainData along with its age. The age is
+// computed from the transaction timestamp, so every endorsing peer returns the same value.
+func (s *SmartContract) ReadSupplyChainDataWithMeta(ctx contractapi.TransactionContextInterface, id string) (*SupplyChainDataMeta, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SupplyChainDataMeta{
+		Data:       supplyChainData,
+		AgeSeconds: int64(now.Sub(supplyChainData.Timestamp).Seconds()),
+	}, nil
+}
+
+// IsExpired reports whether a supply chain data point has passed its expiry time, as of the transaction timestamp.
+// Data created without an expiry never expires.
+func (s *SmartContract) IsExpired(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	// Get the supply chain data from the ledger
+	supplyChainData, err := getSupplyChainData(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if the client is allowed to access this data
+	if !canAccess(clientOrgID, supplyChainData) {
+		return false, readDeniedError(clientOrgID, id)
+	}
+
+	return isExpired(ctx, supplyChainData)
+}
+
+// GetSupplyChainDataHistory returns every version a supply chain data point has gone through, including deletions.
+// Access is checked against the latest version that was not a deletion, so deleted data keeps the same visibility.
+func (s *SmartContract) GetSupplyChainDataHistory(ctx contractapi.TransactionContextInterface, id string) ([]HistoryEntry, error) {
+	if !isSupplyChainDataKey(id) {
+		return nil, fmt.Errorf("%w: the supply chain data %s does not exist", ErrNotFound, id)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// Collect the history, remembering the latest version that was not a deletion
+	var history []HistoryEntry
+	var latest *HistoryEntry
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := HistoryEntry{
+			TxID:      modification.TxId,
+			Timestamp: modification.Timestamp.AsTime(),
+			IsDelete:  modification.IsDelete,
+		}
+		if !modification.IsDelete {
+			var supplyChainData SupplyChainData
+			err = json.Unmarshal(modification.Value, &supplyChainData)
+			if err != nil {
+				return nil, err
+			}
+			entry.Value = &supplyChainData
+
+			if latest == nil || entry.Timestamp.After(latest.Timestamp) {
+				latest = &entry
+			}
+		}
+
+		history = append(history, entry)
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("%w: the supply chain data %s does not exist", ErrNotFound, id)
+	}
+
+	// Check if the client is allowed to access this data
+	if !canAccess(clientOrgID, latest.Value) {
+		return nil, readDeniedError(clientOrgID, id)
+	}
+
+	// Hide the fields the owner currently redacts in every version, so they cannot be read from older versions
+	for i := range history {
+		if history[i].Value != nil {
+			history[i].Value = redactHistoricalVersion(clientOrgID, latest.Value, history[i].Value)
+		}
+	}
+
+	return history, nil
+}
+
+// DeleteSupplyChainData removes a supply chain data point from the ledger. Only the owning organization can delete it.
+func (s *SmartContract) DeleteSupplyChainData(ctx contractapi.TransactionContextInterface, id string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// Remove the data from the ledger
+	err = ctx.GetStub().DelState(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete from world state: %v", err)
+	}
+	err = deleteDataTypeIndex(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "DeleteSupplyChainData", supplyChainData.ID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so downstream indexers can drop the data
+	return emitEvent(ctx, "DataDeleted", struct {
+		ID             string `json:"id"`
+		OrganizationID string `json:"organizationId"`
+	}{supplyChainData.ID, supplyChainData.OrganizationID})
+}
+
+// UpdateAccessControl replaces the list of organizations that can access a supply chain data point.
+// Only the owning organization can update it; the owner always retains access implicitly. The new list may only add
+// organizations: removing one needs the approval of the other organizations, so it goes through RevokeDataAccess.
+func (s *SmartContract) UpdateAccessControl(ctx contractapi.TransactionContextInterface, id string, accessControl []string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	for _, org := range accessControl {
+		if strings.TrimSpace(org) == "" {
+			return fmt.Errorf("%w: access control list must not contain an empty organization", ErrInvalidArgument)
+		}
+	}
+
+	// Always keep the organization required for this data type, if any
+	accessControl, err = withMandatoryAccess(ctx, supplyChainData.DataType, supplyChainData.OrganizationID, normalizeOrgIDs(accessControl))
+	if err != nil {
+		return err
+	}
+
+	err = checkNoAccessRemoved(supplyChainData, accessControl)
+	if err != nil {
+		return err
+	}
+
+	// Update the access control list
+	supplyChainData.AccessControl = accessControl
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "UpdateAccessControl", supplyChainData.ID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so partner organizations can refresh their cached permissions
+	return emitEvent(ctx, "AccessControlUpdated", struct {
+		ID            string   `json:"id"`
+		AccessControl []string `json:"accessControl"`
+	}{supplyChainData.ID, supplyChainData.AccessControl})
+}
+
+// UpdateEncryptedData replaces the encrypted payload and its hash after an off-chain correction.
+// Only the owning organization can update it; the creation Timestamp is kept and LastModified is bumped.
+func (s *SmartContract) UpdateEncryptedData(ctx contractapi.TransactionContextInterface, id, encryptedData, dataHash string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if !isValidDataHash(dataHash) {
+		return fmt.Errorf("%w: data hash must be a %d character hex-encoded SHA-256 digest", ErrInvalidArgument, sha256.Size*2)
+	}
+
+	// Replace the payload and its hash
+	oldDataHash := supplyChainData.DataHash
+	supplyChainData.EncryptedData = encryptedData
+	supplyChainData.DataHash = dataHash
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "UpdateEncryptedData", supplyChainData.ID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event so auditors can track changes to integrity-critical fields
+	return emitEvent(ctx, "DataUpdated", struct {
+		ID          string `json:"id"`
+		OldDataHash string `json:"oldDataHash"`
+		NewDataHash string `json:"newDataHash"`
+	}{id, oldDataHash, dataHash})
+}
+
+// ArchiveSupplyChainData marks a supply chain data point as archived instead of deleting it, keeping it on the
+// ledger for audits while excluding it from normal queries. Only the owning organization can archive it.
+func (s *SmartContract) ArchiveSupplyChainData(ctx contractapi.TransactionContextInterface, id string) error {
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if supplyChainData.Archived {
+		return fmt.Errorf("%w: the supply chain data %s is already archived", ErrConflict, id)
+	}
+
+	// Archive the data
+	supplyChainData.Archived = true
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	return writeAuditEntry(ctx, "ArchiveSupplyChainData", id)
+}
+
+// TransferOwnership moves a supply chain data point to another organization. Only the current owner can
+// transfer it, and the previous owner is added to AccessControl so it retains read access. If nonce is not empty,
+// the transfer is rejected when the nonce was used before, protecting against replayed requests. newOrganizationID is
+// trimmed and stored as spelled in the registry or, for an unregistered organization, as spelled on AccessControl.
+// Org-scoped queries match owners case-insensitively, so the new owner finds the data either way.
+func (s *SmartContract) TransferOwnership(ctx contractapi.TransactionContextInterface, id, newOrganizationID, nonce string) error {
+	// Tidy up the new owner like an access control list entry
+	newOrganizationID = strings.TrimSpace(newOrganizationID)
+	if newOrganizationID == "" {
+		return fmt.Errorf("%w: new organization must not be empty", ErrInvalidArgument)
+	}
+
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	err = consumeNonce(ctx, nonce)
+	if err != nil {
+		return err
+	}
+
+	// Catch organizations that are not part of the network and use the registered spelling
+	err = s.checkRegisteredOrgs(ctx, []string{newOrganizationID})
+	if err != nil {
+		return err
+	}
+	registered, err := s.IsRegisteredOrg(ctx, newOrganizationID)
+	if err != nil {
+		return err
+	}
+	if registered {
+		newOrganizationID, err = registeredOrgID(ctx, newOrganizationID)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Otherwise use the spelling the organization already has on the access control list, if any
+		for _, org := range supplyChainData.AccessControl {
+			if sameOrg(org, newOrganizationID) {
+				newOrganizationID = org
+				break
+			}
+		}
+	}
+
+	previousOrganizationID := supplyChainData.OrganizationID
+	if sameOrg(newOrganizationID, previousOrganizationID) {
+		return fmt.Errorf("%w: the supply chain data %s is already owned by organization %s", ErrConflict, id, newOrganizationID)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Transfer ownership, keeping read access for the previous owner
+	supplyChainData.OwnershipHistory = append(ownershipHistory(supplyChainData), OwnershipRecord{
+		OrganizationID: newOrganizationID,
+		AcquiredAt:     now,
+	})
+	supplyChainData.OrganizationID = newOrganizationID
+	if !containsOrg(supplyChainData.AccessControl, previousOrganizationID) {
+		supplyChainData.AccessControl = append(supplyChainData.AccessControl, previousOrganizationID)
+	}
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	// From now on, changes need the new owner's endorsement instead of the previous owner's
+	err = setOwnerEndorsementPolicy(ctx, id, newOrganizationID)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuditEntry(ctx, "TransferOwnership", supplyChainData.ID)
+	if err != nil {
+		return err
+	}
+
+	// Emit an event recording the transfer
+	return emitEvent(ctx, "OwnershipTransferred", struct {
+		ID                     string `json:"id"`
+		PreviousOrganizationID string `json:"previousOrganizationId"`
+		NewOrganizationID      string `json:"newOrganizationId"`
+	}{id, previousOrganizationID, newOrganizationID})
+}
+
+// ReassignDataType changes the data type of every supply chain data point of fromType owned by the organization,
+// including archived data, e.g. after a batch was mislabeled, and returns the number of data points changed. The
+// organization required for toType, if any, is given access. Only the organization itself can reassign its data,
+// and nothing is reassigned while any of the data points is disputed.
+func (s *SmartContract) ReassignDataType(ctx contractapi.TransactionContextInterface, fromType, toType, organizationID string) (int, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if the client is allowed to change data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return 0, fmt.Errorf("%w: client from organization %s is not authorized to reassign data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	err = ValidateDataType(toType)
+	if err != nil {
+		return 0, err
+	}
+	if fromType == toType {
+		return 0, fmt.Errorf("%w: data is already of type %s", ErrInvalidArgument, toType)
+	}
+
+	// Query the ledger for the organization's data of the old type
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"dataType":       fromType,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return 0, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []string{}
+	for _, supplyChainData := range results {
+		err = checkNotDisputed(supplyChainData)
+		if err != nil {
+			return 0, err
+		}
+
+		// Move the data type index entry along with the data
+		err = deleteDataTypeIndex(ctx, supplyChainData)
+		if err != nil {
+			return 0, err
+		}
+		supplyChainData.DataType = toType
+
+		// Always share the data with the organization required for its new type, if any
+		supplyChainData.AccessControl, err = withMandatoryAccess(ctx, toType, supplyChainData.OrganizationID, supplyChainData.AccessControl)
+		if err != nil {
+			return 0, err
+		}
+
+		err = putSupplyChainData(ctx, supplyChainData)
+		if err != nil {
+			return 0, err
+		}
+		err = putDataTypeIndex(ctx, supplyChainData)
+		if err != nil {
+			return 0, err
+		}
+		err = writeAuditEntry(ctx, "ReassignDataType", supplyChainData.ID)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, supplyChainData.ID)
+	}
+
+	if len(ids) > 0 {
+		// Fabric keeps only the last event set in a transaction, so a single event lists every reclassified ID
+		err = emitEvent(ctx, "DataReclassified", DataReclassifiedEvent{
+			EventHeader:    EventHeader{EventVersion: dataReclassifiedEventVersion},
+			OrganizationID: clientOrgID,
+			FromType:       fromType,
+			ToType:         toType,
+			IDs:            ids,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// GetOwnershipHistory returns every owner of a supply chain data point in order, starting with the organization
+// that created it and ending with the current owner
+func (s *SmartContract) GetOwnershipHistory(ctx contractapi.TransactionContextInterface, id string) ([]OwnershipRecord, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ownershipHistory(supplyChainData), nil
+}
+
+// ownershipHistory returns the ownership history of supply chain data, which only holds the creating organization
+// until the data is first transferred
+func ownershipHistory(supplyChainData *SupplyChainData) []OwnershipRecord {
+	if len(supplyChainData.OwnershipHistory) > 0 {
+		return supplyChainData.OwnershipHistory
+	}
+	return []OwnershipRecord{{
+		OrganizationID: supplyChainData.OrganizationID,
+		AcquiredAt:     supplyChainData.Timestamp,
+	}}
+}
+
+// QuerySupplyChainDataByOrg returns all supply chain data for a specific organization, excluding archived data
+func (s *SmartContract) QuerySupplyChainDataByOrg(ctx contractapi.TransactionContextInterface, organizationID string) ([]*SupplyChainData, error) {
+	return s.querySupplyChainDataByOrg(ctx, organizationID, false)
+}
+
+// QuerySupplyChainDataByOrgIncludingArchived returns all supply chain data for a specific organization, including archived data
+func (s *SmartContract) QuerySupplyChainDataByOrgIncludingArchived(ctx contractapi.TransactionContextInterface, organizationID string) ([]*SupplyChainData, error) {
+	return s.querySupplyChainDataByOrg(ctx, organizationID, true)
+}
+
+func (s *SmartContract) querySupplyChainDataByOrg(ctx contractapi.TransactionContextInterface, organizationID string, includeArchived bool) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Query the ledger for all data belonging to this organization
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{"organizationId": orgIDCondition(clientOrgID)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Collect the results
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	if !includeArchived {
+		results = excludeArchived(results)
+	}
+
+	return excludeExpired(ctx, results)
+}
+
+// QueryDataSharedWithMe returns the supply chain data other organizations have shared with the client's
+// organization, excluding archived data. It is the counterpart of QuerySupplyChainDataByOrg, which returns the
+// data the client's organization owns.
+func (s *SmartContract) QueryDataSharedWithMe(ctx contractapi.TransactionContextInterface) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data listing the client's organization in its access control list
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"accessControl": map[string]interface{}{"$elemMatch": orgIDCondition(clientOrgID)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the client's own data, which is also listed when an owner shares data with itself
+	shared := []*SupplyChainData{}
+	for _, supplyChainData := range excludeArchived(filterByAccess(clientOrgID, results)) {
+		if !sameOrg(clientOrgID, supplyChainData.OrganizationID) {
+			shared = append(shared, supplyChainData)
+		}
+	}
+
+	return excludeExpired(ctx, shared)
+}
+
+// GetAccessSummary returns how many supply chain data points the client's organization owns and how many other
+// organizations have shared with it, excluding archived data. Both counts come from a single query.
+func (s *SmartContract) GetAccessSummary(ctx contractapi.TransactionContextInterface) (*AccessSummary, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := queryOwnedOrSharedData(ctx, clientOrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tally owned and shared data in one pass
+	summary := AccessSummary{OrganizationID: clientOrgID}
+	for _, supplyChainData := range results {
+		if sameOrg(clientOrgID, supplyChainData.OrganizationID) {
+			summary.OwnedCount++
+		} else if canAccess(clientOrgID, supplyChainData) {
+			summary.SharedCount++
+		}
+	}
+
+	return &summary, nil
+}
+
+// ExportAccessibleData returns every supply chain data point the client's organization owns or has been granted
+// access to, excluding archived data, as a single JSON array for offline analysis.
+//
+// The whole export is built in memory and returned in one response, which is subject to the gRPC message size limit
+// of the peer and client (4 MB by default). For large data sets use QuerySupplyChainDataByOrgPaginated and
+// GetAllSupplyChainDataPaginated instead.
+func (s *SmartContract) ExportAccessibleData(ctx contractapi.TransactionContextInterface) (string, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	results, err := queryOwnedOrSharedData(ctx, clientOrgID)
+	if err != nil {
+		return "", err
+	}
+
+	exportJSON, err := json.Marshal(filterByAccess(clientOrgID, results))
+	if err != nil {
+		return "", err
+	}
+
+	return string(exportJSON), nil
+}
+
+// queryOwnedOrSharedData returns the supply chain data an organization owns or is listed in the access control list
+// of, excluding archived and expired data, with a single query
+func queryOwnedOrSharedData(ctx contractapi.TransactionContextInterface, orgID string) ([]*SupplyChainData, error) {
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"$or": []interface{}{
+				map[string]interface{}{"organizationId": orgIDCondition(orgID)},
+				map[string]interface{}{"accessControl": map[string]interface{}{"$elemMatch": orgIDCondition(orgID)}},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return excludeExpired(ctx, excludeArchived(results))
+}
+
+// CountSupplyChainDataByOrg returns the number of supply chain data points of an organization, excluding archived
+// and expired data like QuerySupplyChainDataByOrg, without building the full result set in memory
+func (s *SmartContract) CountSupplyChainDataByOrg(ctx contractapi.TransactionContextInterface, organizationID string) (int, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return 0, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Query the ledger for all data belonging to this organization
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{"organizationId": orgIDCondition(clientOrgID)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return 0, err
+	}
+	defer resultIterator.Close()
+
+	// Count the results, decoding only the fields needed to skip archived and expired data
+	count := 0
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		// Skip if this is not supply chain data (e.g., access policies)
+		if !isSupplyChainDataKey(queryResult.Key) {
+			continue
+		}
+
+		var status struct {
+			Archived  bool      `json:"archived"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		}
+		err = json.Unmarshal(queryResult.Value, &status)
+		if err != nil {
+			return 0, err
+		}
+		if status.Archived {
+			continue
+		}
+
+		expired, err := isExpired(ctx, &SupplyChainData{ExpiresAt: status.ExpiresAt})
+		if err != nil {
+			return 0, err
+		}
+		if !expired {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// QuerySupplyChainDataByOrgPaginated returns one page of the supply chain data for a specific organization, excluding
+// archived data. Expired data is dropped from the page, so a page may hold fewer than pageSize records. Pass an empty
+// bookmark to start from the beginning; the last page is returned with an empty bookmark.
+func (s *SmartContract) QuerySupplyChainDataByOrgPaginated(ctx contractapi.TransactionContextInterface, organizationID string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("%w: page size must be positive, got %d", ErrInvalidArgument, pageSize)
+	}
+
+	// Query the ledger for one page of data belonging to this organization
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"archived":       map[string]interface{}{"$ne": true},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJSON), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Collect the results
+	records, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	records, err = excludeExpired(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+
+	// A short page means there is nothing left to fetch
+	nextBookmark := responseMetadata.Bookmark
+	if responseMetadata.FetchedRecordsCount < pageSize {
+		nextBookmark = ""
+	}
+
+	return &PaginatedQueryResult{
+		Records:             records,
+		FetchedRecordsCount: int32(len(records)),
+		Bookmark:            nextBookmark,
+	}, nil
+}
+
+// QuerySupplyChainDataByType returns all supply chain data of a given type that the client can access.
+// It uses the type~id composite key index, so it does not need a rich query capable state database.
+func (s *SmartContract) QuerySupplyChainDataByType(ctx contractapi.TransactionContextInterface, dataType string) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(dataTypeIndex, []string{dataType})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	// Look up each indexed ID, filtering for access control
+	results := []*SupplyChainData{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 2 {
+			continue
+		}
+
+		supplyChainDataJSON, err := ctx.GetStub().GetState(compositeKeyParts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if supplyChainDataJSON == nil {
+			continue // Stale index entry
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(supplyChainDataJSON, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if the client is allowed to access this data
+		if canAccess(clientOrgID, &supplyChainData) {
+			results = append(results, redactForClient(clientOrgID, &supplyChainData))
+		}
+	}
+
+	return excludeExpired(ctx, results)
+}
+
+// GetLatestByDataType returns an organization's most recently created supply chain data point of a data type,
+// ignoring archived and expired data. The sort requires the indexOrgTypeTimestamp CouchDB index shipped in
+// META-INF/statedb/couchdb/indexes/indexOrgTypeTimestamp.json.
+func (s *SmartContract) GetLatestByDataType(ctx contractapi.TransactionContextInterface, organizationID, dataType string) (*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Query the ledger for the organization's data of this type, newest first
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"dataType":       dataType,
+			"timestamp":      map[string]interface{}{"$gt": nil},
+		},
+		"sort":      []map[string]string{{"organizationId": "desc"}, {"dataType": "desc"}, {"timestamp": "desc"}},
+		"use_index": []string{"_design/indexOrgTypeTimestampDoc", "indexOrgTypeTimestamp"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Return the first result that is still current
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(queryResult.Value, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+		if supplyChainData.Archived {
+			continue
+		}
+
+		expired, err := isExpired(ctx, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+		if !expired {
+			return &supplyChainData, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: organization %s has no %s data", ErrNotFound, organizationID, dataType)
+}
+
+// QuerySupplyChainDataByTimeRange returns the supply chain data of an organization with a timestamp between start and end, inclusive
+func (s *SmartContract) QuerySupplyChainDataByTimeRange(ctx contractapi.TransactionContextInterface, organizationID, startRFC3339, endRFC3339 string) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Parse and validate the time range
+	start, end, err := parseTimeRange(startRFC3339, endRFC3339)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for the organization's data within the time range
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"timestamp":      timeRangeCondition(start, end),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Collect the results within the exact time range
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	inRange := []*SupplyChainData{}
+	for _, supplyChainData := range results {
+		if withinTimeRange(supplyChainData.Timestamp, start, end) {
+			inRange = append(inRange, supplyChainData)
+		}
+	}
+
+	return excludeExpired(ctx, inRange)
+}
+
+// GetModifiedSince returns the organization's supply chain data changed after sinceRFC3339, for incremental syncs of
+// off-chain caches. Archived data is included so caches also learn about archiving.
+func (s *SmartContract) GetModifiedSince(ctx contractapi.TransactionContextInterface, organizationID, sinceRFC3339 string) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	since, err := time.Parse(time.RFC3339, sinceRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid since time %q, expected RFC 3339: %v", ErrInvalidArgument, sinceRFC3339, err)
+	}
+
+	// Query the ledger for the organization's data modified after the given time
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"lastModified":   timeRangeCondition(since, time.Time{}),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Collect the results modified strictly after the given time
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	modified := []*SupplyChainData{}
+	for _, supplyChainData := range results {
+		if supplyChainData.LastModified.After(since) {
+			modified = append(modified, supplyChainData)
+		}
+	}
+
+	return excludeExpired(ctx, modified)
+}
+
+// QuerySupplyChainDataByIDRange returns the supply chain data the client can access with an ID from startID,
+// inclusive, to endID, exclusive, in ID order and excluding archived data. It uses a range scan, so it does not need
+// a rich query capable state database.
+func (s *SmartContract) QuerySupplyChainDataByIDRange(ctx contractapi.TransactionContextInterface, startID, endID string) ([]*SupplyChainData, error) {
+	if startID == "" || endID == "" {
+		return nil, fmt.Errorf("%w: start and end IDs must not be empty", ErrInvalidArgument)
+	}
+	if startID >= endID {
+		return nil, fmt.Errorf("%w: start ID %s must sort before end ID %s", ErrInvalidArgument, startID, endID)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startID, endID)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results, err := collectSupplyChainDataFromRange(resultsIterator, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, filterByAccess(clientOrgID, results))
+}
+
+// QueryAnomalies returns all supply chain data points with detected anomalies
+func (s *SmartContract) QueryAnomalies(ctx contractapi.TransactionContextInterface) ([]*SupplyChainData, error) {
+	// Query the ledger for all data with anomalies
+	queryString := `{"selector":{"anomalyDetected":true}}`
+	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the results, filtering for access control
+	var results []*SupplyChainData
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(queryResult.Value, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if the client is allowed to access this data
+		if canAccess(clientOrgID, &supplyChainData) {
+			results = append(results, redactForClient(clientOrgID, &supplyChainData))
+		}
+	}
+
+	return excludeExpired(ctx, results)
+}
+
+// GetRecentAnomalies returns the limit most recently created supply chain data points with detected anomalies that
+// the client can access, newest first. The sort requires the indexAnomalyTimestamp CouchDB index shipped in
+// META-INF/statedb/couchdb/indexes/indexAnomalyTimestamp.json, which Fabric deploys along with the chaincode:
+//
+//	{"index":{"fields":[{"anomalyDetected":"desc"},{"timestamp":"desc"}]},"ddoc":"indexAnomalyTimestampDoc","name":"indexAnomalyTimestamp","type":"json"}
+//
+// Access control is applied after retrieval, so results are read until limit accessible records have been found
+// rather than capping the query itself, which could return fewer than limit records.
+func (s *SmartContract) GetRecentAnomalies(ctx contractapi.TransactionContextInterface, limit int) ([]*SupplyChainData, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive, got %d", ErrInvalidArgument, limit)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data with anomalies, newest first
+	queryString := `{"selector":{"anomalyDetected":true,"timestamp":{"$gt":null}},` +
+		`"sort":[{"anomalyDetected":"desc"},{"timestamp":"desc"}],` +
+		`"use_index":["_design/indexAnomalyTimestampDoc","indexAnomalyTimestamp"]}`
+	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	// Collect the results, filtering for access control, until the limit is reached
+	results := []*SupplyChainData{}
+	for resultIterator.HasNext() && len(results) < limit {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(queryResult.Value, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if the client is allowed to access this data
+		if !canAccess(clientOrgID, &supplyChainData) {
+			continue
+		}
+
+		expired, err := isExpired(ctx, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+		if !expired {
+			results = append(results, redactForClient(clientOrgID, &supplyChainData))
+		}
+	}
+
+	return results, nil
+}
+
+// GetAnomalyStatsByOrg returns anomaly counts and scores for an organization's supply chain data
+func (s *SmartContract) GetAnomalyStatsByOrg(ctx contractapi.TransactionContextInterface, organizationID string) (*AnomalyStats, error) {
+	// Get the organization's data, enforcing that the client belongs to it
+	records, err := s.QuerySupplyChainDataByOrg(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := AnomalyStats{
+		OrganizationID: organizationID,
+		TotalRecords:   len(records),
+	}
+	totalScore := 0.0
+	for _, supplyChainData := range records {
+		if !supplyChainData.AnomalyDetected {
+			continue
+		}
+		stats.AnomalyCount++
+		totalScore += supplyChainData.AnomalyScore
+		if supplyChainData.AnomalyScore > stats.MaxAnomalyScore {
+			stats.MaxAnomalyScore = supplyChainData.AnomalyScore
+		}
+	}
+	if stats.AnomalyCount > 0 {
+		stats.AverageAnomalyScore = totalScore / float64(stats.AnomalyCount)
+	}
+
+	return &stats, nil
+}
+
+// GetAnomalyRate returns the fraction, from 0.0 to 1.0, of an organization's supply chain data created between
+// startRFC3339 and endRFC3339, inclusive, that has a detected anomaly. It is 0 when there is no data in the window.
+func (s *SmartContract) GetAnomalyRate(ctx contractapi.TransactionContextInterface, organizationID, startRFC3339, endRFC3339 string) (float64, error) {
+	// Get the organization's data in the window, enforcing that the client belongs to it
+	records, err := s.QuerySupplyChainDataByTimeRange(ctx, organizationID, startRFC3339, endRFC3339)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	anomalyCount := 0
+	for _, supplyChainData := range records {
+		if supplyChainData.AnomalyDetected {
+			anomalyCount++
+		}
+	}
+
+	return float64(anomalyCount) / float64(len(records)), nil
+}
+
+// GetDataTypeSummary returns how many supply chain data points an organization has of each data type, excluding
+// archived data. The map is empty, not nil, when the organization has no data.
+func (s *SmartContract) GetDataTypeSummary(ctx contractapi.TransactionContextInterface, organizationID string) (map[string]int, error) {
+	// Get the organization's data, enforcing that the client belongs to it
+	records, err := s.QuerySupplyChainDataByOrg(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]int)
+	for _, supplyChainData := range records {
+		summary[supplyChainData.DataType]++
+	}
+
+	return summary, nil
+}
+
+// GetAnomalyTrend returns the number of an organization's supply chain data points with detected anomalies created
+// in each day or hour between start and end, inclusive. bucket is "day" or "hour"; the keys are the RFC 3339 UTC
+// start times of the buckets. Buckets without anomalies are left out.
+func (s *SmartContract) GetAnomalyTrend(ctx contractapi.TransactionContextInterface, organizationID, startRFC3339, endRFC3339, bucket string) (map[string]int, error) {
+	var bucketSize time.Duration
+	switch bucket {
+	case "day":
+		bucketSize = 24 * time.Hour
+	case "hour":
+		bucketSize = time.Hour
+	default:
+		return nil, fmt.Errorf("%w: bucket must be \"day\" or \"hour\", got %q", ErrInvalidArgument, bucket)
+	}
+
+	// Get the organization's data in the time range, enforcing that the client belongs to it
+	records, err := s.QuerySupplyChainDataByTimeRange(ctx, organizationID, startRFC3339, endRFC3339)
+	if err != nil {
+		return nil, err
+	}
+
+	trend := make(map[string]int)
+	for _, supplyChainData := range records {
+		if !supplyChainData.AnomalyDetected {
+			continue
+		}
+		bucketStart := supplyChainData.Timestamp.UTC().Truncate(bucketSize)
+		trend[bucketStart.Format(time.RFC3339)]++
+	}
+
+	return trend, nil
+}
+
+// QueryAnomaliesByThreshold returns the supply chain data points with detected anomalies scoring at least minScore
+func (s *SmartContract) QueryAnomaliesByThreshold(ctx contractapi.TransactionContextInterface, minScore float64) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data with anomalies at or above the threshold
+	queryString := fmt.Sprintf(`{"selector":{"anomalyDetected":true,"anomalyScore":{"$gte":%g}}}`, minScore)
+	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, filterByAccess(clientOrgID, results))
+}
+
+// QueryAnomaliesByScoreRange returns the supply chain data points with detected anomalies scoring between minScore
+// and maxScore, inclusive, e.g. 0.5 to 0.8 to triage medium-severity anomalies
+func (s *SmartContract) QueryAnomaliesByScoreRange(ctx contractapi.TransactionContextInterface, minScore, maxScore float64) ([]*SupplyChainData, error) {
+	if minScore < 0 || maxScore > 1 {
+		return nil, fmt.Errorf("%w: scores must be between 0.0 and 1.0, got %g to %g", ErrInvalidArgument, minScore, maxScore)
+	}
+	if minScore > maxScore {
+		return nil, fmt.Errorf("%w: minimum score %g is greater than maximum score %g", ErrInvalidArgument, minScore, maxScore)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data with anomalies in the score range
+	queryString := fmt.Sprintf(`{"selector":{"anomalyDetected":true,"anomalyScore":{"$gte":%g,"$lte":%g}}}`, minScore, maxScore)
+	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, filterByAccess(clientOrgID, results))
+}
+
+// QuerySupplyChainData returns the supply chain data the client can access matching every field present in
+// filterJSON, excluding archived data. filterJSON is a JSON object with any of organizationId, dataType,
+// anomalyDetected, startTime and endTime, the latter two bounding the creation timestamp as RFC 3339 times, e.g.
+// {"dataType":"shipment","anomalyDetected":true}. Unknown fields are rejected so a typo cannot widen the query.
+func (s *SmartContract) QuerySupplyChainData(ctx contractapi.TransactionContextInterface, filterJSON string) ([]*SupplyChainData, error) {
+	// Parse the filter
+	var filter supplyChainDataFilter
+	decoder := json.NewDecoder(strings.NewReader(filterJSON))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid filter: %v", ErrInvalidArgument, err)
+	}
+
+	// Build the selector from the fields present
+	selector := map[string]interface{}{}
+	if filter.OrganizationID != "" {
+		selector["organizationId"] = orgIDCondition(filter.OrganizationID)
+	}
+	if filter.DataType != "" {
+		err = ValidateDataType(filter.DataType)
+		if err != nil {
+			return nil, err
+		}
+		selector["dataType"] = filter.DataType
+	}
+	if filter.AnomalyDetected != nil {
+		selector["anomalyDetected"] = *filter.AnomalyDetected
+	}
+	var start, end time.Time
+	if filter.StartTime != "" {
+		start, err = time.Parse(time.RFC3339, filter.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid start time %q, expected RFC 3339: %v", ErrInvalidArgument, filter.StartTime, err)
+		}
+	}
+	if filter.EndTime != "" {
+		end, err = time.Parse(time.RFC3339, filter.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid end time %q, expected RFC 3339: %v", ErrInvalidArgument, filter.EndTime, err)
+		}
+	}
+	if !start.IsZero() || !end.IsZero() {
+		selector["timestamp"] = timeRangeCondition(start, end)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryJSON, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	inRange := []*SupplyChainData{}
+	for _, supplyChainData := range results {
+		if withinTimeRange(supplyChainData.Timestamp, start, end) {
+			inRange = append(inRange, supplyChainData)
+		}
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, excludeArchived(filterByAccess(clientOrgID, inRange)))
+}
+
+// QueryWithSelector runs a caller-supplied CouchDB Mango selector and returns the matching supply chain data
+// that the client can access. selectorJSON is the selector object only, e.g. {"dataType":"shipment"}.
+func (s *SmartContract) QueryWithSelector(ctx contractapi.TransactionContextInterface, selectorJSON string) ([]*SupplyChainData, error) {
+	// Validate the selector is a non-empty JSON object
+	var selector map[string]interface{}
+	err := json.Unmarshal([]byte(selectorJSON), &selector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: selector must be a JSON object: %v", ErrInvalidArgument, err)
+	}
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("%w: selector must not be empty", ErrInvalidArgument)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrap the selector in a query
+	queryJSON, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, filterByAccess(clientOrgID, results))
+}
+
+// FindByDataHash returns the supply chain data the client can access whose DataHash matches dataHash. Clients can
+// use it to detect that a payload was already submitted under another ID before creating a copy.
+func (s *SmartContract) FindByDataHash(ctx contractapi.TransactionContextInterface, dataHash string) ([]*SupplyChainData, error) {
+	if !isValidDataHash(dataHash) {
+		return nil, fmt.Errorf("%w: data hash must be a %d character hex-encoded SHA-256 digest", ErrInvalidArgument, sha256.Size*2)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data with this hash
+	queryString := fmt.Sprintf(`{"selector":{"dataHash":"%s"}}`, dataHash)
+	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, filterByAccess(clientOrgID, results))
+}
+
+// GetRecordsWithoutHash returns the organization's supply chain data with an empty DataHash, such as data created
+// with CreateSupplyChainDataSimple, excluding archived data. Such data cannot be integrity-checked until a hash is
+// backfilled with PatchSupplyChainData.
+func (s *SmartContract) GetRecordsWithoutHash(ctx contractapi.TransactionContextInterface, organizationID string) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query data for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query data for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Query the ledger for the organization's data with an empty or missing hash
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"$or": []interface{}{
+				map[string]interface{}{"dataHash": ""},
+				map[string]interface{}{"dataHash": map[string]interface{}{"$exists": false}},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return excludeExpired(ctx, excludeArchived(results))
+}
+
+// CreateAccessPolicy creates a new access policy. Every data type must be valid and no allowed organization may be
+// empty; duplicates are dropped.
+func (s *SmartContract) CreateAccessPolicy(ctx contractapi.TransactionContextInterface, id, organizationID string, dataTypes, allowedOrgs []string) error {
+	// Check if the policy already exists
+	exists, err := s.AccessPolicyExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: the access policy %s already exists", ErrAlreadyExists, id)
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client belongs to the organization they claim to represent
+	if !sameOrg(clientOrgID, organizationID) {
+		return fmt.Errorf("%w: client from organization %s cannot create policy for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Store the owner as spelled in the client's certificate and tidy up the data types and allowed organizations
+	organizationID = clientOrgID
+	dataTypes, allowedOrgs, err = normalizeAccessPolicy(dataTypes, allowedOrgs)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the access policy object
+	accessPolicy := AccessPolicy{
+		ID:             id,
+		OrganizationID: organizationID,
+		DataTypes:      dataTypes,
+		AllowedOrgs:    allowedOrgs,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// Convert to JSON
+	accessPolicyJSON, err := json.Marshal(accessPolicy)
+	if err != nil {
+		return err
+	}
+
+	// Put the policy on the ledger
+	return ctx.GetStub().PutState(policyKeyPrefix+id, accessPolicyJSON)
+}
+
+// ReadAccessPolicy returns the access policy stored in the ledger
+func (s *SmartContract) ReadAccessPolicy(ctx contractapi.TransactionContextInterface, id string) (*AccessPolicy, error) {
+	// Get the access policy from the ledger
+	accessPolicy, err := getAccessPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to read this policy
+	if !sameOrg(clientOrgID, accessPolicy.OrganizationID) && !containsOrg(accessPolicy.AllowedOrgs, clientOrgID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to read this policy", ErrUnauthorized, clientOrgID)
+	}
+
+	return accessPolicy, nil
+}
+
+// UpdateAccessPolicy replaces the data types and allowed organizations of an existing access policy, validating them
+// like CreateAccessPolicy. Only the owning organization can update it.
+func (s *SmartContract) UpdateAccessPolicy(ctx contractapi.TransactionContextInterface, id string, dataTypes, allowedOrgs []string) error {
+	// Get the access policy from the ledger
+	accessPolicy, err := getAccessPolicy(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client owns the policy
+	if !sameOrg(clientOrgID, accessPolicy.OrganizationID) {
+		return fmt.Errorf("%w: client from organization %s cannot update policy for organization %s", ErrUnauthorized, clientOrgID, accessPolicy.OrganizationID)
+	}
+
+	// Tidy up the data types and allowed organizations like CreateAccessPolicy
+	dataTypes, allowedOrgs, err = normalizeAccessPolicy(dataTypes, allowedOrgs)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Update the policy, keeping its creation time
+	accessPolicy.DataTypes = dataTypes
+	accessPolicy.AllowedOrgs = allowedOrgs
+	accessPolicy.UpdatedAt = now
+
+	// Convert to JSON
+	accessPolicyJSON, err := json.Marshal(accessPolicy)
+	if err != nil {
+		return err
+	}
+
+	// Put the policy back on the ledger
+	return ctx.GetStub().PutState(policyKeyPrefix+id, accessPolicyJSON)
+}
+
+// DeleteAccessPolicy removes an access policy from the ledger. Only the owning organization can delete it.
+func (s *SmartContract) DeleteAccessPolicy(ctx contractapi.TransactionContextInterface, id string) error {
+	// Get the access policy from the ledger
+	accessPolicy, err := getAccessPolicy(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client owns the policy
+	if !sameOrg(clientOrgID, accessPolicy.OrganizationID) {
+		return fmt.Errorf("%w: client from organization %s cannot delete policy for organization %s", ErrUnauthorized, clientOrgID, accessPolicy.OrganizationID)
+	}
+
+	// Remove the policy from the ledger
+	err = ctx.GetStub().DelState(policyKeyPrefix + id)
+	if err != nil {
+		return fmt.Errorf("failed to delete from world state: %v", err)
+	}
+
+	// Emit an event so policy enforcement automation can invalidate its caches
+	return emitEvent(ctx, "PolicyDeleted", struct {
+		ID             string `json:"id"`
+		OrganizationID string `json:"organizationId"`
+	}{id, accessPolicy.OrganizationID})
+}
+
+// QueryAccessPoliciesByOrg returns all access policies owned by a specific organization
+func (s *SmartContract) QueryAccessPoliciesByOrg(ctx contractapi.TransactionContextInterface, organizationID string) ([]*AccessPolicy, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client is allowed to query policies for this organization
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to query policies for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Query the ledger for the organization's policies, which share the organizationId field with supply chain data
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"_id":            map[string]string{"$regex": "^" + policyKeyPrefix},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	return collectAccessPoliciesFromIterator(resultIterator)
+}
+
+// QueryPoliciesGrantingAccess returns the access policies of every owner that share data with the client's
+// organization, so partners can see where their access comes from
+func (s *SmartContract) QueryPoliciesGrantingAccess(ctx contractapi.TransactionContextInterface) ([]*AccessPolicy, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for policies listing the client's organization
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"_id":         map[string]interface{}{"$regex": "^" + policyKeyPrefix},
+			"allowedOrgs": map[string]interface{}{"$elemMatch": orgIDCondition(clientOrgID)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	return collectAccessPoliciesFromIterator(resultIterator)
+}
+
+// GetDataByPolicy returns the policy owner's supply chain data whose data type the access policy covers, excluding
+// archived data, so the owner can see which data a policy change would affect. Only the policy owner can call it.
+func (s *SmartContract) GetDataByPolicy(ctx contractapi.TransactionContextInterface, policyID string) ([]*SupplyChainData, error) {
+	accessPolicy, err := getAccessPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the owner's data, enforcing that the client is the policy owner
+	records, err := s.QuerySupplyChainDataByOrg(ctx, accessPolicy.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := []*SupplyChainData{}
+	for _, supplyChainData := range records {
+		if contains(accessPolicy.DataTypes, supplyChainData.DataType) {
+			affected = append(affected, supplyChainData)
+		}
+	}
+
+	return affected, nil
+}
+
+// SetMandatoryAccess requires that all supply chain data of the given type is shared with requiredOrg.
+// Only the admin organization can set mandatory access.
+func (s *SmartContract) SetMandatoryAccess(ctx contractapi.TransactionContextInterface, dataType, requiredOrg string) error {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is the admin organization
+	if !sameOrg(clientOrgID, adminMSPID) {
+		return fmt.Errorf("%w: client from organization %s is not authorized to set mandatory access", ErrUnauthorized, clientOrgID)
+	}
+
+	if dataType == "" || requiredOrg == "" {
+		return fmt.Errorf("%w: data type and required organization must not be empty", ErrInvalidArgument)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the mandatory access object
+	mandatoryAccess := MandatoryAccess{
+		DataType:    dataType,
+		RequiredOrg: requiredOrg,
+		UpdatedAt:   now,
+	}
+
+	// Convert to JSON
+	mandatoryAccessJSON, err := json.Marshal(mandatoryAccess)
+	if err != nil {
+		return err
+	}
+
+	// Put the mandatory access on the ledger
+	return ctx.GetStub().PutState(mandatoryAccessKeyPrefix+dataType, mandatoryAccessJSON)
+}
+
+// CreateSupplyChainDataSimple adds supply chain data with JSON payload (for testing).
+// It skips data hash validation and stores an empty DataHash. The data is owned by the client's organization and
+// shared according to the owner's access policies and mandatory access, as with CreateSupplyChainData, so it can
+// never be attributed to another organization.
+func (s *SmartContract) CreateSupplyChainDataSimple(ctx contractapi.TransactionContextInterface, id, jsonData string) error {
+	if id == "" || !isSupplyChainDataKey(id) {
+		return fmt.Errorf("%w: invalid supply chain data id %q", ErrInvalidArgument, id)
+	}
+
+	// Check if the data already exists
+	exists, err := s.supplyChainDataExists(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to check if data exists: %v", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: the supply chain data %s already exists", ErrAlreadyExists, id)
+	}
+
+	// Parse the JSON data
+	var dataMap map[string]interface{}
+	err = json.Unmarshal([]byte(jsonData), &dataMap)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse JSON data: %v", ErrInvalidArgument, err)
+	}
+
+	// The data is always owned by the client's organization
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Share the data as the owner's access policies and mandatory access require
+	accessControl, err := withPolicyAccess(ctx, DataTypeSupplyChain, clientOrgID, []string{})
+	if err != nil {
+		return err
+	}
+	accessControl, err = withMandatoryAccess(ctx, DataTypeSupplyChain, clientOrgID, accessControl)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create a simple supply chain data object with the JSON data
+	supplyChainData := SupplyChainData{
+		ID:              id,
+		OrganizationID:  clientOrgID,
+		Timestamp:       now,
+		LastModified:    now,
+		EncryptedData:   jsonData,
+		DataHash:        "",
+		DataType:        DataTypeSupplyChain,
+		AccessControl:   accessControl,
+		AnomalyDetected: false,
+		AnomalyScore:    0.0,
+		Explanation:     "",
+
+		SchemaVersion:    currentSchemaVersion,
+		EncryptionScheme: EncryptionSchemeNone,
+	}
+
+	// Put the data and its index entries on the ledger
+	return putNewSupplyChainData(ctx, &supplyChainData)
+}
+
+// GetAllSupplyChainData returns all supply chain data the client can access, excluding archived data (for testing),
+// with the fields the owner redacted blanked. A RESULT_TOO_LARGE error is returned if there are more than
+// maxGetAllResults such data points.
+func (s *SmartContract) GetAllSupplyChainData(ctx contractapi.TransactionContextInterface) ([]*SupplyChainData, error) {
+	return s.getAllSupplyChainData(ctx, false)
+}
+
+// GetAllSupplyChainDataIncludingArchived returns all supply chain data the client can access, including archived data
+// (for testing), with the fields the owner redacted blanked. A RESULT_TOO_LARGE error is returned if there are more
+// than maxGetAllResults such data points.
+func (s *SmartContract) GetAllSupplyChainDataIncludingArchived(ctx contractapi.TransactionContextInterface) ([]*SupplyChainData, error) {
+	return s.getAllSupplyChainData(ctx, true)
+}
+
+func (s *SmartContract) getAllSupplyChainData(ctx contractapi.TransactionContextInterface, includeArchived bool) ([]*SupplyChainData, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use rich query with empty selector to get all data
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results := []*SupplyChainData{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip if this is not supply chain data (e.g., access policies)
+		if !isSupplyChainDataKey(queryResponse.Key) {
+			continue
+		}
+
+		var data SupplyChainData
+		err = json.Unmarshal(queryResponse.Value, &data)
+		if err != nil {
+			continue // Skip malformed data
+		}
+
+		if data.Archived && !includeArchived {
+			continue
+		}
+		if !canAccess(clientOrgID, &data) {
+			continue
+		}
+		expired, err := isExpired(ctx, &data)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			continue
+		}
+
+		// Fail clearly instead of returning a response the peer would reject for exceeding the message size limit,
+		// without reading the rest of the ledger
+		if len(results) == maxGetAllResults {
+			return nil, fmt.Errorf("%w: more than %d supply chain data points, use GetAllSupplyChainDataPaginated", ErrResultTooLarge, maxGetAllResults)
+		}
+		results = append(results, redactForClient(clientOrgID, &data))
+	}
+
+	return results, nil
+}
+
+// GetAllSupplyChainDataPaginated returns one page of all supply chain data the client can access, excluding archived
+// data, with the fields the owner redacted blanked.
+// Pass an empty bookmark to start from the beginning; the last page is returned with an empty bookmark.
+// A page may hold fewer than pageSize records because other kinds of ledger entries are skipped.
+func (s *SmartContract) GetAllSupplyChainDataPaginated(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("%w: page size must be positive, got %d", ErrInvalidArgument, pageSize)
+	}
+
+	// Scan one page of keys; the bookmark is the key the next page starts from, so skipped
+	// entries such as access policies never cause records to be missed
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination("", "", pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := collectSupplyChainDataFromRange(resultsIterator, false)
+	if err != nil {
+		return nil, err
+	}
+	records, err = excludeExpired(ctx, filterByAccess(clientOrgID, records))
+	if err != nil {
+		return nil, err
+	}
+
+	// A short page of keys means there is nothing left to fetch. This is based on the number of
+	// keys scanned rather than the number of records returned, which can be lower.
+	nextBookmark := responseMetadata.Bookmark
+	if responseMetadata.FetchedRecordsCount < pageSize {
+		nextBookmark = ""
+	}
+
+	return &PaginatedQueryResult{
+		Records:             records,
+		FetchedRecordsCount: int32(len(records)),
+		Bookmark:            nextBookmark,
+	}, nil
+}
+
+// VerifyDataIntegrity returns true if the SHA-256 of the supplied plaintext matches the stored DataHash.
+// An error is only returned if the data cannot be read by the client.
+func (s *SmartContract) VerifyDataIntegrity(ctx contractapi.TransactionContextInterface, id, plaintext string) (bool, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	// Hash the plaintext and compare it with the committed hash
+	digest := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(digest[:]) == strings.ToLower(supplyChainData.DataHash), nil
+}
+
+// GetDataHash returns only the DataHash of a supply chain data point, for integrity checks that do not need the payload
+func (s *SmartContract) GetDataHash(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	return supplyChainData.DataHash, nil
+}
+
+// GetOrgDataFingerprint returns a hex-encoded SHA-256 digest over the DataHash of every supply chain data point the
+// organization owns, including archived data, taken in ID order. Two parties holding the same data set compute the
+// same fingerprint, so they can compare data sets without exchanging them.
+func (s *SmartContract) GetOrgDataFingerprint(ctx contractapi.TransactionContextInterface, organizationID string) (string, error) {
+	// Get all of the organization's data, verifying the client belongs to it
+	results, err := s.querySupplyChainDataByOrg(ctx, organizationID, true)
+	if err != nil {
+		return "", err
+	}
+
+	// Query results come back in no guaranteed order, so sort them to make the fingerprint deterministic
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+
+	digest := sha256.New()
+	for _, supplyChainData := range results {
+		digest.Write([]byte(supplyChainData.DataHash))
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// GetWriteReceipt returns a receipt for the latest committed write of a supply chain data point, naming the
+// transaction that made it. Clients can store the receipt and later check with VerifyReceipt that the write is on the
+// ledger, even after the data changed again. The peers must keep a history database.
+func (s *SmartContract) GetWriteReceipt(ctx contractapi.TransactionContextInterface, id string) (*WriteReceipt, error) {
+	// Get the supply chain data, enforcing access control
+	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the transaction that wrote the current version
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if modification.IsDelete {
+			continue
+		}
+
+		return &WriteReceipt{
+			ID:           supplyChainData.ID,
+			DataHash:     supplyChainData.DataHash,
+			Timestamp:    supplyChainData.Timestamp,
+			LastModified: supplyChainData.LastModified,
+			Version:      supplyChainData.Version,
+			TxID:         modification.TxId,
+			TxTimestamp:  modification.Timestamp.AsTime(),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: the supply chain data %s has no committed write", ErrNotFound, id)
+}
+
+// VerifyReceipt returns true if the ledger history of a supply chain data point contains the write the receipt
+// describes: a transaction with the receipt's ID and timestamp that wrote the receipt's data hash, timestamps and
+// version. A receipt with any field altered does not match the ledger and is reported as false.
+func (s *SmartContract) VerifyReceipt(ctx contractapi.TransactionContextInterface, id, receiptJSON string) (bool, error) {
+	// Parse the receipt
+	var receipt WriteReceipt
+	err := json.Unmarshal([]byte(receiptJSON), &receipt)
+	if err != nil {
+		return false, fmt.Errorf("%w: failed to parse receipt: %v", ErrInvalidArgument, err)
+	}
+	if receipt.ID != id {
+		return false, nil
+	}
+
+	// Check the client can access the supply chain data
+	_, err = s.ReadSupplyChainData(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	// Look for the write in the history
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to read history from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return false, err
+		}
+		if modification.TxId != receipt.TxID || modification.IsDelete {
+			continue
+		}
+
+		var written SupplyChainData
+		err = json.Unmarshal(modification.Value, &written)
+		if err != nil {
+			return false, err
+		}
+
+		return modification.Timestamp.AsTime().Equal(receipt.TxTimestamp) &&
+			written.DataHash == receipt.DataHash &&
+			written.Timestamp.Equal(receipt.Timestamp) &&
+			written.LastModified.Equal(receipt.LastModified) &&
+			written.Version == receipt.Version, nil
+	}
+
+	return false, nil
+}
+
+// RequestReprocessing emits a ReprocessRequested event listing the organization's supply chain data of the
+// given type so that the external anomaly detection service re-scores them. Archived and expired data is left out.
+// The records themselves are not modified.
+func (s *SmartContract) RequestReprocessing(ctx contractapi.TransactionContextInterface, organizationID, dataType string) ([]string, error) {
+	err := ValidateDataType(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the owning organization may request reprocessing of its data
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to request reprocessing for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	// Query the ledger for the organization's data of this type
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"organizationId": orgIDCondition(clientOrgID),
+			"dataType":       dataType,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+	results, err = excludeExpired(ctx, excludeArchived(results))
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the IDs of the matching data
+	ids := []string{}
+	for _, supplyChainData := range results {
+		ids = append(ids, supplyChainData.ID)
+	}
+
+	// Emit an event so the anomaly detection service knows which data to re-score
+	err = emitEvent(ctx, "ReprocessRequested", struct {
+		OrganizationID string   `json:"organizationId"`
+		DataType       string   `json:"dataType"`
+		IDs            []string `json:"ids"`
+	}{organizationID, dataType, ids})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GenerateTamperReport runs the tamper-evidence checks across all current supply chain data of an organization,
+// leaving out archived and expired data
+func (s *SmartContract) GenerateTamperReport(ctx contractapi.TransactionContextInterface, organizationID string) (*TamperReport, error) {
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the owning organization may generate a report for its data
+	if !sameOrg(clientOrgID, organizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s is not authorized to generate a report for organization %s", ErrUnauthorized, clientOrgID, organizationID)
+	}
+
+	txTimestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data belonging to this organization
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{"organizationId": orgIDCondition(clientOrgID)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	report := TamperReport{
+		OrganizationID:        organizationID,
+		GeneratedAt:           txTimestamp,
+		HashConsistency:       TamperCheck{OffendingIDs: []string{}},
+		KeyConsistency:        TamperCheck{OffendingIDs: []string{}},
+		OwnershipConsistency:  TamperCheck{OffendingIDs: []string{}},
+		TimestampPlausibility: TamperCheck{OffendingIDs: []string{}},
+		DanglingReferences:    TamperCheck{OffendingIDs: []string{}},
+	}
+	parentExists := make(map[string]bool) // Parents already looked up
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip if this is not supply chain data (e.g., access policies)
+		if !isSupplyChainDataKey(queryResult.Key) {
+			continue
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(queryResult.Value, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+		if supplyChainData.Archived {
+			continue
+		}
+		expired, err := isExpired(ctx, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			continue
+		}
+		report.RecordCount++
+
+		if !isValidDataHash(supplyChainData.DataHash) {
+			report.HashConsistency.OffendingIDs = append(report.HashConsistency.OffendingIDs, queryResult.Key)
+		}
+		if supplyChainData.ID != queryResult.Key {
+			report.KeyConsistency.OffendingIDs = append(report.KeyConsistency.OffendingIDs, queryResult.Key)
+		}
+		owners := ownershipHistory(&supplyChainData)
+		if !sameOrg(owners[len(owners)-1].OrganizationID, supplyChainData.OrganizationID) {
+			report.OwnershipConsistency.OffendingIDs = append(report.OwnershipConsistency.OffendingIDs, queryResult.Key)
+		}
+		if supplyChainData.Timestamp.IsZero() || supplyChainData.Timestamp.After(txTimestamp) {
+			report.TimestampPlausibility.OffendingIDs = append(report.TimestampPlausibility.OffendingIDs, queryResult.Key)
+		}
+
+		// A parent may be deleted after its children were created
+		for _, parentID := range supplyChainData.ParentIDs {
+			exists, ok := parentExists[parentID]
+			if !ok {
+				exists, err = s.supplyChainDataExists(ctx, parentID)
+				if err != nil {
+					return nil, err
+				}
+				parentExists[parentID] = exists
+			}
+			if !exists {
+				report.DanglingReferences.OffendingIDs = append(report.DanglingReferences.OffendingIDs, queryResult.Key)
+				break
+			}
+		}
+	}
+
+	report.HashConsistency.Passed = len(report.HashConsistency.OffendingIDs) == 0
+	report.KeyConsistency.Passed = len(report.KeyConsistency.OffendingIDs) == 0
+	report.OwnershipConsistency.Passed = len(report.OwnershipConsistency.OffendingIDs) == 0
+	report.TimestampPlausibility.Passed = len(report.TimestampPlausibility.OffendingIDs) == 0
+	report.DanglingReferences.Passed = len(report.DanglingReferences.OffendingIDs) == 0
+	report.Passed = report.HashConsistency.Passed && report.KeyConsistency.Passed && report.OwnershipConsistency.Passed &&
+		report.TimestampPlausibility.Passed && report.DanglingReferences.Passed
+
+	return &report, nil
+}
+
+// GetValidDataTypes returns the data types accepted for supply chain data
+func (s *SmartContract) GetValidDataTypes(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	return append([]string{}, validDataTypes...), nil
+}
+
+// CanAccessData returns true if the supply chain data with the given ID exists and the client is allowed to read
+// it. Data owned by other organizations and not shared with the client is reported as false, like missing data.
+func (s *SmartContract) CanAccessData(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	_, err := s.ReadSupplyChainData(ctx, id)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnauthorized) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// supplyChainDataExists returns true if the supply chain data with the given ID exists. It bypasses access control,
+// so it is only for checks inside the contract, such as refusing to create duplicate IDs, and is not exposed to
+// clients, who use CanAccessData instead.
+func (s *SmartContract) supplyChainDataExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	supplyChainDataJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return supplyChainDataJSON != nil, nil
+}
+
+// AccessPolicyExists returns true if the access policy with the given ID exists
+func (s *SmartContract) AccessPolicyExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	accessPolicyJSON, err := ctx.GetStub().GetState(policyKeyPrefix + id)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return accessPolicyJSON != nil, nil
+}
+
+// ValidateDataType returns an error if dataType is not one of the accepted data types
+func ValidateDataType(dataType string) error {
+	if !contains(validDataTypes, dataType) {
+		return fmt.Errorf("%w: invalid data type %q, must be one of: %s", ErrInvalidArgument, dataType, strings.Join(validDataTypes, ", "))
+	}
+	return nil
+}
+
+// Helper function to get the organization ID of the client submitting the transaction
+func getClientOrgID(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client organization: %v", err)
+	}
+
+	return clientOrgID, nil
+}
+
+// Helper function to get supply chain data from the ledger without any access control check
+func getSupplyChainData(ctx contractapi.TransactionContextInterface, id string) (*SupplyChainData, error) {
+	// Other kinds of ledger entries are never returned as supply chain data
+	if !isSupplyChainDataKey(id) {
+		return nil, fmt.Errorf("%w: the supply chain data %s does not exist", ErrNotFound, id)
+	}
+
+	supplyChainDataJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if supplyChainDataJSON == nil {
+		return nil, fmt.Errorf("%w: the supply chain data %s does not exist", ErrNotFound, id)
+	}
+
+	// Convert the JSON to a SupplyChainData object
+	var supplyChainData SupplyChainData
+	err = json.Unmarshal(supplyChainDataJSON, &supplyChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &supplyChainData, nil
+}
+
+// Helper function to collect the supply chain data returned by a range scan, skipping other kinds of ledger
+// entries and malformed data
+func collectSupplyChainDataFromRange(resultsIterator shim.StateQueryIteratorInterface, includeArchived bool) ([]*SupplyChainData, error) {
+	supplyChainData := []*SupplyChainData{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip if this is not supply chain data (e.g., access policies)
+		if !isSupplyChainDataKey(queryResponse.Key) {
+			continue
+		}
+
+		var data SupplyChainData
+		err = json.Unmarshal(queryResponse.Value, &data)
+		if err != nil {
+			continue // Skip malformed data
+		}
+
+		if data.Archived && !includeArchived {
+			continue
+		}
+
+		supplyChainData = append(supplyChainData, &data)
+	}
+
+	return supplyChainData, nil
+}
+
+// Helper function to collect the supply chain data returned by a query, skipping other kinds of ledger entries
+func constructQueryResponseFromIterator(resultIterator shim.StateQueryIteratorInterface) ([]*SupplyChainData, error) {
+	results := []*SupplyChainData{}
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip if this is not supply chain data (e.g., access policies)
+		if !isSupplyChainDataKey(queryResult.Key) {
+			continue
+		}
+
+		var supplyChainData SupplyChainData
+		err = json.Unmarshal(queryResult.Value, &supplyChainData)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &supplyChainData)
+	}
+
+	return results, nil
+}
+
+// Helper function to collect the access policies from a query result iterator, skipping any other entries
+func collectAccessPoliciesFromIterator(resultIterator shim.StateQueryIteratorInterface) ([]*AccessPolicy, error) {
+	accessPolicies := []*AccessPolicy{}
+	for resultIterator.HasNext() {
+		queryResult, err := resultIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip anything that is not an access policy
+		if !strings.HasPrefix(queryResult.Key, policyKeyPrefix) {
+			continue
+		}
+
+		var accessPolicy AccessPolicy
+		err = json.Unmarshal(queryResult.Value, &accessPolicy)
+		if err != nil {
+			return nil, err
+		}
+
+		accessPolicies = append(accessPolicies, &accessPolicy)
 	}
 
-	// Convert to JSON
+	return accessPolicies, nil
+}
+
+// Helper function to put supply chain data on the ledger under its ID, incrementing its Version and setting its
+// LastModified to the transaction timestamp
+func putSupplyChainData(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) error {
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	supplyChainData.Version++
+	supplyChainData.LastModified = now
+
 	supplyChainDataJSON, err := json.Marshal(supplyChainData)
 	if err != nil {
 		return err
 	}
 
-	// Put the data on the ledger
-	return ctx.GetStub().PutState(id, supplyChainDataJSON)
+	return ctx.GetStub().PutState(supplyChainData.ID, supplyChainDataJSON)
 }
 
-// UpdateAnomalyStatus updates the anomaly status of a supply chain data point
-func (s *SmartContract) UpdateAnomalyStatus(ctx contractapi.TransactionContextInterface, id string, anomalyDetected bool, anomalyScore float64, explanation string) error {
-	// Get the supply chain data
-	supplyChainData, err := s.ReadSupplyChainData(ctx, id)
+// Helper function to put newly created supply chain data on the ledger along with its index entries and owner
+// endorsement policy, emitting a DataCreated event
+func putNewSupplyChainData(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) error {
+	err := putSupplyChainData(ctx, supplyChainData)
 	if err != nil {
 		return err
 	}
 
-	// Update the anomaly status
-	supplyChainData.AnomalyDetected = anomalyDetected
-	supplyChainData.AnomalyScore = anomalyScore
-	supplyChainData.Explanation = explanation
+	err = setOwnerEndorsementPolicy(ctx, supplyChainData.ID, supplyChainData.OrganizationID)
+	if err != nil {
+		return err
+	}
 
-	// Convert to JSON
-	supplyChainDataJSON, err := json.Marshal(supplyChainData)
+	err = putDataTypeIndex(ctx, supplyChainData)
 	if err != nil {
 		return err
 	}
 
-	// Put the data back on the ledger
-	err = ctx.GetStub().PutState(id, supplyChainDataJSON)
+	err = writeAuditEntry(ctx, "CreateSupplyChainData", supplyChainData.ID)
 	if err != nil {
 		return err
 	}
 
-	// Emit an event if an anomaly was detected
-	if anomalyDetected {
-		eventPayload := fmt.Sprintf("{\"id\":\"%s\",\"organizationId\":\"%s\",\"dataType\":\"%s\",\"anomalyScore\":%f}",
-			supplyChainData.ID, supplyChainData.OrganizationID, supplyChainData.DataType, anomalyScore)
-		err = ctx.GetStub().SetEvent("AnomalyDetected", []byte(eventPayload))
-		if err != nil {
-			return err
-		}
+	// Emit an event so downstream systems can index new data without polling
+	return emitEvent(ctx, "DataCreated", struct {
+		ID             string    `json:"id"`
+		OrganizationID string    `json:"organizationId"`
+		DataType       string    `json:"dataType"`
+		Timestamp      time.Time `json:"timestamp"`
+	}{supplyChainData.ID, supplyChainData.OrganizationID, supplyChainData.DataType, supplyChainData.Timestamp})
+}
+
+// Helper function to require the endorsement of a peer of the owning organization for any future change to the
+// supply chain data key. Without it, the chaincode-level endorsement policy would let other organizations' peers
+// alone endorse a transaction that rewrites data they do not own. The policy covers every write to the key, so the
+// writes other organizations are allowed to make also need the owner's endorsement: MarkDisputed, the anomaly
+// resolution approvals, UpdateAnomalyStatus by a grantee and MigrateRecords by the admin. An owner whose peers
+// refuse to endorse can therefore block them; the other organizations can still see the refusal, since the
+// transactions fail validation with an ENDORSEMENT_POLICY_FAILURE status.
+func setOwnerEndorsementPolicy(ctx contractapi.TransactionContextInterface, id, ownerOrgID string) error {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, ownerOrgID)
+	if err != nil {
+		return fmt.Errorf("failed to add organization %s to the endorsement policy: %v", ownerOrgID, err)
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to create the endorsement policy: %v", err)
+	}
+
+	err = ctx.GetStub().SetStateValidationParameter(id, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set the endorsement policy of %s: %v", id, err)
 	}
 
 	return nil
 }
 
-// ReadSupplyChainData returns the supply chain data stored in the ledger
-func (s *SmartContract) ReadSupplyChainData(ctx contractapi.TransactionContextInterface, id string) (*SupplyChainData, error) {
-	// Get the supply chain data from the ledger
-	supplyChainDataJSON, err := ctx.GetStub().GetState(id)
+// Helper function to add supply chain data to the data type index
+func putDataTypeIndex(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(dataTypeIndex, []string{supplyChainData.DataType, supplyChainData.ID})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
-	if supplyChainDataJSON == nil {
-		return nil, fmt.Errorf("the supply chain data %s does not exist", id)
+
+	// Only the key is needed, the value must not be empty for the entry to be stored
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// Helper function to remove supply chain data from the data type index
+func deleteDataTypeIndex(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(dataTypeIndex, []string{supplyChainData.DataType, supplyChainData.ID})
+	if err != nil {
+		return err
 	}
 
-	// Convert the JSON to a SupplyChainData object
-	var supplyChainData SupplyChainData
-	err = json.Unmarshal(supplyChainDataJSON, &supplyChainData)
+	return ctx.GetStub().DelState(indexKey)
+}
+
+// Helper function to get supply chain data that is owned by the organization of the client submitting the transaction
+func getOwnedSupplyChainData(ctx contractapi.TransactionContextInterface, id string) (*SupplyChainData, error) {
+	supplyChainData, err := getSupplyChainData(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -152,271 +3572,327 @@ func (s *SmartContract) ReadSupplyChainData(ctx contractapi.TransactionContextIn
 		return nil, err
 	}
 
-	// Check if the client is allowed to access this data
-	if clientOrgID != supplyChainData.OrganizationID && !contains(supplyChainData.AccessControl, clientOrgID) {
-		return nil, fmt.Errorf("client from organization %s is not authorized to read this data", clientOrgID)
+	// Being listed in AccessControl is not enough, the client must be the owner
+	if !sameOrg(clientOrgID, supplyChainData.OrganizationID) {
+		return nil, fmt.Errorf("%w: client from organization %s does not own the supply chain data %s", ErrUnauthorized, clientOrgID, id)
 	}
 
-	return &supplyChainData, nil
+	return supplyChainData, nil
 }
 
-// QuerySupplyChainDataByOrg returns all supply chain data for a specific organization
-func (s *SmartContract) QuerySupplyChainDataByOrg(ctx contractapi.TransactionContextInterface, organizationID string) ([]*SupplyChainData, error) {
-	// Get the identity of the client submitting the transaction
-	clientOrgID, err := getClientOrgID(ctx)
+// Helper function to emit a chaincode event with a JSON payload
+func emitEvent(ctx contractapi.TransactionContextInterface, eventName string, payload interface{}) error {
+	eventPayload, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Check if the client is allowed to query data for this organization
-	if clientOrgID != organizationID {
-		return nil, fmt.Errorf("client from organization %s is not authorized to query data for organization %s", clientOrgID, organizationID)
+	return ctx.GetStub().SetEvent(eventName, eventPayload)
+}
+
+// Helper function to get an access policy from the ledger without any access control check
+func getAccessPolicy(ctx contractapi.TransactionContextInterface, id string) (*AccessPolicy, error) {
+	accessPolicyJSON, err := ctx.GetStub().GetState(policyKeyPrefix + id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if accessPolicyJSON == nil {
+		return nil, fmt.Errorf("%w: the access policy %s does not exist", ErrNotFound, id)
 	}
 
-	// Query the ledger for all data belonging to this organization
-	queryString := fmt.Sprintf(`{"selector":{"organizationId":"%s"}}`, organizationID)
-	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	// Convert the JSON to an AccessPolicy object
+	var accessPolicy AccessPolicy
+	err = json.Unmarshal(accessPolicyJSON, &accessPolicy)
 	if err != nil {
 		return nil, err
 	}
-	defer resultIterator.Close()
 
-	// Collect the results
-	var results []*SupplyChainData
-	for resultIterator.HasNext() {
-		queryResult, err := resultIterator.Next()
+	return &accessPolicy, nil
+}
+
+// Helper function to get all access policies owned by an organization. It uses a range scan over the policy keys
+// rather than a rich query so that it works on any state database and is safe to call from update transactions.
+func getAccessPoliciesByOrg(ctx contractapi.TransactionContextInterface, organizationID string) ([]*AccessPolicy, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange(policyKeyPrefix, prefixRangeEnd(policyKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var accessPolicies []*AccessPolicy
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
 		if err != nil {
 			return nil, err
 		}
 
-		var supplyChainData SupplyChainData
-		err = json.Unmarshal(queryResult.Value, &supplyChainData)
+		var accessPolicy AccessPolicy
+		err = json.Unmarshal(queryResponse.Value, &accessPolicy)
 		if err != nil {
 			return nil, err
 		}
 
-		results = append(results, &supplyChainData)
+		if sameOrg(accessPolicy.OrganizationID, organizationID) {
+			accessPolicies = append(accessPolicies, &accessPolicy)
+		}
 	}
 
-	return results, nil
+	return accessPolicies, nil
 }
 
-// QueryAnomalies returns all supply chain data points with detected anomalies
-func (s *SmartContract) QueryAnomalies(ctx contractapi.TransactionContextInterface) ([]*SupplyChainData, error) {
-	// Query the ledger for all data with anomalies
-	queryString := `{"selector":{"anomalyDetected":true}}`
-	resultIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err != nil {
-		return nil, err
-	}
-	defer resultIterator.Close()
-
-	// Get the identity of the client submitting the transaction
-	clientOrgID, err := getClientOrgID(ctx)
+// Helper function to add the organizations allowed by the owner's access policies for a data type to an access control list
+func withPolicyAccess(ctx contractapi.TransactionContextInterface, dataType, ownerOrgID string, accessControl []string) ([]string, error) {
+	accessPolicies, err := getAccessPoliciesByOrg(ctx, ownerOrgID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Collect the results, filtering for access control
-	var results []*SupplyChainData
-	for resultIterator.HasNext() {
-		queryResult, err := resultIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var supplyChainData SupplyChainData
-		err = json.Unmarshal(queryResult.Value, &supplyChainData)
-		if err != nil {
-			return nil, err
+	for _, accessPolicy := range accessPolicies {
+		if !contains(accessPolicy.DataTypes, dataType) {
+			continue
 		}
-
-		// Check if the client is allowed to access this data
-		if clientOrgID == supplyChainData.OrganizationID || contains(supplyChainData.AccessControl, clientOrgID) {
-			results = append(results, &supplyChainData)
+		for _, org := range accessPolicy.AllowedOrgs {
+			if !sameOrg(org, ownerOrgID) && !containsOrg(accessControl, org) {
+				accessControl = append(accessControl, org)
+			}
 		}
 	}
 
-	return results, nil
+	return accessControl, nil
 }
 
-// CreateAccessPolicy creates a new access policy
-func (s *SmartContract) CreateAccessPolicy(ctx contractapi.TransactionContextInterface, id, organizationID string, dataTypes, allowedOrgs []string) error {
-	// Check if the policy already exists
-	exists, err := s.AccessPolicyExists(ctx, id)
+// Helper function to get the organization that must have access to a data type, or "" if there is none
+func getMandatoryAccessOrg(ctx contractapi.TransactionContextInterface, dataType string) (string, error) {
+	mandatoryAccessJSON, err := ctx.GetStub().GetState(mandatoryAccessKeyPrefix + dataType)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to read from world state: %v", err)
 	}
-	if exists {
-		return fmt.Errorf("the access policy %s already exists", id)
+	if mandatoryAccessJSON == nil {
+		return "", nil
 	}
 
-	// Get the identity of the client submitting the transaction
-	clientOrgID, err := getClientOrgID(ctx)
+	var mandatoryAccess MandatoryAccess
+	err = json.Unmarshal(mandatoryAccessJSON, &mandatoryAccess)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Verify that the client belongs to the organization they claim to represent
-	if clientOrgID != organizationID {
-		return fmt.Errorf("client from organization %s cannot create policy for organization %s", clientOrgID, organizationID)
+	return mandatoryAccess.RequiredOrg, nil
+}
+
+// Helper function to add the organization required for a data type to an access control list, if it is missing
+func withMandatoryAccess(ctx contractapi.TransactionContextInterface, dataType, ownerOrgID string, accessControl []string) ([]string, error) {
+	requiredOrg, err := getMandatoryAccessOrg(ctx, dataType)
+	if err != nil {
+		return nil, err
+	}
+	if requiredOrg != "" && !sameOrg(requiredOrg, ownerOrgID) && !containsOrg(accessControl, requiredOrg) {
+		accessControl = append(accessControl, requiredOrg)
 	}
 
-	// Create the access policy object
-	accessPolicy := AccessPolicy{
-		ID:             id,
-		OrganizationID: organizationID,
-		DataTypes:      dataTypes,
-		AllowedOrgs:    allowedOrgs,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+	return accessControl, nil
+}
+
+// Helper function to get the exclusive end key of a range scan over all keys starting with prefix
+func prefixRangeEnd(prefix string) string {
+	return prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+}
+
+// Helper function to check if a ledger key holds supply chain data rather than another kind of entry
+func isSupplyChainDataKey(key string) bool {
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
 	}
+	return true
+}
 
-	// Convert to JSON
-	accessPolicyJSON, err := json.Marshal(accessPolicy)
+// Helper function to get the deterministic timestamp of the transaction being executed. Every endorsing peer
+// sees the same value, unlike time.Now(), so it must be used for anything written to the ledger.
+func getTxTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
 	}
 
-	// Put the policy on the ledger
-	return ctx.GetStub().PutState(fmt.Sprintf("POLICY_%s", id), accessPolicyJSON)
+	return txTimestamp.AsTime(), nil
 }
 
-// ReadAccessPolicy returns the access policy stored in the ledger
-func (s *SmartContract) ReadAccessPolicy(ctx contractapi.TransactionContextInterface, id string) (*AccessPolicy, error) {
-	// Get the access policy from the ledger
-	accessPolicyJSON, err := ctx.GetStub().GetState(fmt.Sprintf("POLICY_%s", id))
-	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if accessPolicyJSON == nil {
-		return nil, fmt.Errorf("the access policy %s does not exist", id)
+// Helper function to check if a data hash is a hex-encoded SHA-256 digest
+func isValidDataHash(dataHash string) bool {
+	if len(dataHash) != sha256.Size*2 {
+		return false
 	}
+	_, err := hex.DecodeString(dataHash)
+	return err == nil
+}
 
-	// Convert the JSON to an AccessPolicy object
-	var accessPolicy AccessPolicy
-	err = json.Unmarshal(accessPolicyJSON, &accessPolicy)
+// Helper function to parse an RFC 3339 time range, rejecting ranges that start after they end
+func parseTimeRange(startRFC3339, endRFC3339 string) (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, startRFC3339)
 	if err != nil {
-		return nil, err
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid start time %q, expected RFC 3339: %v", ErrInvalidArgument, startRFC3339, err)
 	}
-
-	// Get the identity of the client submitting the transaction
-	clientOrgID, err := getClientOrgID(ctx)
+	end, err := time.Parse(time.RFC3339, endRFC3339)
 	if err != nil {
-		return nil, err
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid end time %q, expected RFC 3339: %v", ErrInvalidArgument, endRFC3339, err)
 	}
-
-	// Check if the client is allowed to read this policy
-	if clientOrgID != accessPolicy.OrganizationID && !contains(accessPolicy.AllowedOrgs, clientOrgID) {
-		return nil, fmt.Errorf("client from organization %s is not authorized to read this policy", clientOrgID)
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: start time %s is after end time %s", ErrInvalidArgument, startRFC3339, endRFC3339)
 	}
 
-	return &accessPolicy, nil
+	return start, end, nil
 }
 
-// CreateSupplyChainDataSimple adds supply chain data with JSON payload (for testing)
-func (s *SmartContract) CreateSupplyChainDataSimple(ctx contractapi.TransactionContextInterface, id, jsonData string) error {
-	// Check if the data already exists
-	exists, err := s.SupplyChainDataExists(ctx, id)
-	if err != nil {
-		return fmt.Errorf("failed to check if data exists: %v", err)
+// Helper function to build a selector condition on a timestamp field matching every time from the second of start
+// through the second of end, where a zero start or end leaves that side open. Timestamps are stored in UTC as RFC 3339
+// strings, whose fractional seconds do not sort in time order ("...:01.5Z" sorts before "...:01Z"), so the bounds are
+// whole-second prefixes, which do, and callers must filter the results by the parsed time, e.g. with withinTimeRange.
+func timeRangeCondition(start, end time.Time) map[string]interface{} {
+	const secondLayout = "2006-01-02T15:04:05"
+	condition := map[string]interface{}{}
+	if !start.IsZero() {
+		condition["$gte"] = start.UTC().Truncate(time.Second).Format(secondLayout)
 	}
-	if exists {
-		return fmt.Errorf("the supply chain data %s already exists", id)
+	if !end.IsZero() {
+		condition["$lt"] = end.UTC().Truncate(time.Second).Add(time.Second).Format(secondLayout)
 	}
+	return condition
+}
 
-	// Parse the JSON data
-	var dataMap map[string]interface{}
-	err = json.Unmarshal([]byte(jsonData), &dataMap)
-	if err != nil {
-		return fmt.Errorf("failed to parse JSON data: %v", err)
-	}
+// Helper function to check if a time is between start and end, inclusive, where a zero start or end leaves that side
+// open
+func withinTimeRange(t, start, end time.Time) bool {
+	return (start.IsZero() || !t.Before(start)) && (end.IsZero() || !t.After(end))
+}
 
-	// Create a simple supply chain data object with the JSON data
-	supplyChainData := SupplyChainData{
-		ID:              id,
-		OrganizationID:  "Org1MSP", // Default organization for testing
-		Timestamp:       time.Now(),
-		EncryptedData:   jsonData,
-		DataHash:        "",
-		DataType:        "supply_chain",
-		AccessControl:   []string{"Org1MSP", "Org2MSP", "Org3MSP"},
-		AnomalyDetected: false,
-		AnomalyScore:    0.0,
-		Explanation:     "",
+// Helper function to build the error returned when a client reads supply chain data it cannot access, honoring
+// hideInaccessibleData
+func readDeniedError(clientOrgID, id string) error {
+	if hideInaccessibleData {
+		return fmt.Errorf("%w: the supply chain data %s does not exist", ErrNotFound, id)
 	}
+	return fmt.Errorf("%w: client from organization %s is not authorized to read this data", ErrUnauthorized, clientOrgID)
+}
 
-	// Convert to JSON
-	supplyChainDataJSON, err := json.Marshal(supplyChainData)
-	if err != nil {
-		return err
+// Helper function to check if an organization owns or has been granted access to supply chain data
+func canAccess(clientOrgID string, supplyChainData *SupplyChainData) bool {
+	return sameOrg(clientOrgID, supplyChainData.OrganizationID) || containsOrg(supplyChainData.AccessControl, clientOrgID)
+}
+
+// Helper function to keep only the supply chain data an organization owns or has been granted access to, with the
+// redacted fields of data it does not own blanked
+func filterByAccess(clientOrgID string, records []*SupplyChainData) []*SupplyChainData {
+	accessible := []*SupplyChainData{}
+	for _, supplyChainData := range records {
+		if canAccess(clientOrgID, supplyChainData) {
+			accessible = append(accessible, redactForClient(clientOrgID, supplyChainData))
+		}
 	}
+	return accessible
+}
 
-	// Put the data on the ledger
-	return ctx.GetStub().PutState(id, supplyChainDataJSON)
+// Helper function to drop archived supply chain data
+func excludeArchived(records []*SupplyChainData) []*SupplyChainData {
+	active := []*SupplyChainData{}
+	for _, supplyChainData := range records {
+		if !supplyChainData.Archived {
+			active = append(active, supplyChainData)
+		}
+	}
+	return active
 }
 
-// GetAllSupplyChainData returns all supply chain data (for testing)
-func (s *SmartContract) GetAllSupplyChainData(ctx contractapi.TransactionContextInterface) ([]*SupplyChainData, error) {
-	// Use rich query with empty selector to get all data
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+// Helper function to check if supply chain data has passed its expiry time. The transaction timestamp is used
+// rather than the local clock so every endorsing peer reaches the same result.
+func isExpired(ctx contractapi.TransactionContextInterface, supplyChainData *SupplyChainData) (bool, error) {
+	if supplyChainData.ExpiresAt.IsZero() {
+		return false, nil
+	}
+
+	now, err := getTxTimestamp(ctx)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer resultsIterator.Close()
 
-	var supplyChainData []*SupplyChainData
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	return !now.Before(supplyChainData.ExpiresAt), nil
+}
+
+// Helper function to drop expired supply chain data
+func excludeExpired(ctx contractapi.TransactionContextInterface, records []*SupplyChainData) ([]*SupplyChainData, error) {
+	current := []*SupplyChainData{}
+	for _, supplyChainData := range records {
+		expired, err := isExpired(ctx, supplyChainData)
 		if err != nil {
 			return nil, err
 		}
-
-		// Skip if this is not supply chain data (e.g., access policies)
-		if strings.HasPrefix(queryResponse.Key, "POLICY_") {
-			continue
-		}
-
-		var data SupplyChainData
-		err = json.Unmarshal(queryResponse.Value, &data)
-		if err != nil {
-			continue // Skip malformed data
+		if !expired {
+			current = append(current, supplyChainData)
 		}
-
-		supplyChainData = append(supplyChainData, &data)
 	}
+	return current, nil
+}
 
-	return supplyChainData, nil
+// Helper function to build the CouchDB condition matching an MSP ID stored in the ledger the same way sameOrg does
+func orgIDCondition(orgID string) map[string]string {
+	if caseInsensitiveOrgIDs {
+		return map[string]string{"$regex": "(?i)^" + regexp.QuoteMeta(orgID) + "$"}
+	}
+	return map[string]string{"$eq": orgID}
 }
 
-// SupplyChainDataExists returns true if the supply chain data with the given ID exists
-func (s *SmartContract) SupplyChainDataExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	supplyChainDataJSON, err := ctx.GetStub().GetState(id)
-	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+// Helper function to check if two MSP IDs name the same organization, honoring caseInsensitiveOrgIDs.
+// Queries authorized this way should select on the client's MSP ID, which is how owners are stored.
+func sameOrg(a, b string) bool {
+	if caseInsensitiveOrgIDs {
+		return strings.EqualFold(a, b)
 	}
+	return a == b
+}
 
-	return supplyChainDataJSON != nil, nil
+// Helper function to check if an organization is in a list of MSP IDs, honoring caseInsensitiveOrgIDs
+func containsOrg(orgs []string, org string) bool {
+	for _, o := range orgs {
+		if sameOrg(o, org) {
+			return true
+		}
+	}
+	return false
 }
 
-// AccessPolicyExists returns true if the access policy with the given ID exists
-func (s *SmartContract) AccessPolicyExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	accessPolicyJSON, err := ctx.GetStub().GetState(fmt.Sprintf("POLICY_%s", id))
-	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+// Helper function to validate the data types and allowed organizations of an access policy, dropping duplicates
+func normalizeAccessPolicy(dataTypes, allowedOrgs []string) ([]string, []string, error) {
+	normalizedTypes := []string{}
+	for _, dataType := range dataTypes {
+		err := ValidateDataType(dataType)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !contains(normalizedTypes, dataType) {
+			normalizedTypes = append(normalizedTypes, dataType)
+		}
 	}
 
-	return accessPolicyJSON != nil, nil
+	for _, org := range allowedOrgs {
+		if strings.TrimSpace(org) == "" {
+			return nil, nil, fmt.Errorf("%w: allowed organizations must not contain an empty organization", ErrInvalidArgument)
+		}
+	}
+
+	return normalizedTypes, normalizeOrgIDs(allowedOrgs), nil
 }
 
-// Helper function to get the organization ID of the client submitting the transaction
-func getClientOrgID(ctx contractapi.TransactionContextInterface) (string, error) {
-	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return "", fmt.Errorf("failed to get client organization: %v", err)
+// Helper function to trim MSP IDs and drop duplicates, keeping the first spelling of each organization
+func normalizeOrgIDs(orgs []string) []string {
+	normalized := []string{}
+	for _, org := range orgs {
+		org = strings.TrimSpace(org)
+		if !containsOrg(normalized, org) {
+			normalized = append(normalized, org)
+		}
 	}
-
-	return clientOrgID, nil
+	return normalized
 }
 
 // Helper function to check if a string is in a slice
@@ -429,6 +3905,17 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
+// Helper function to return a copy of a list of MSP IDs with every spelling of an organization removed
+func removeOrg(orgs []string, org string) []string {
+	result := []string{}
+	for _, o := range orgs {
+		if !sameOrg(o, org) {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
 func main() {
 	chaincode, err := contractapi.NewChaincode(&SmartContract{})
 	if err != nil {
@@ -0,0 +1,662 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+)
+
+func TestCreateSupplyChainDataIdempotencyKey(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	hash := testDataHash("payload")
+
+	id, err := s.CreateSupplyChainData(stub.as("Org1MSP"), "data1", "Org1MSP", "payload", hash, DataTypeShipment, nil, "key1")
+	mustSucceed(t, err)
+	if id != "data1" {
+		t.Fatalf("expected data1, got %s", id)
+	}
+
+	// A retry returns the same ID without writing anything
+	id, err = s.CreateSupplyChainData(stub.as("Org1MSP"), "data1", "Org1MSP", "payload", hash, DataTypeShipment, nil, "key1")
+	mustSucceed(t, err)
+	if id != "data1" {
+		t.Fatalf("expected the retry to return data1, got %s", id)
+	}
+
+	// Reusing the key for another ID is a conflict
+	_, err = s.CreateSupplyChainData(stub.as("Org1MSP"), "data2", "Org1MSP", "payload", hash, DataTypeShipment, nil, "key1")
+	mustFailWith(t, err, ErrConflict)
+
+	// Another organization's key of the same name is independent
+	id, err = s.CreateSupplyChainData(stub.as("Org2MSP"), "data3", "Org2MSP", "payload", hash, DataTypeShipment, nil, "key1")
+	mustSucceed(t, err)
+	if id != "data3" {
+		t.Fatalf("expected data3, got %s", id)
+	}
+}
+
+func TestTransferOwnershipNormalizesNewOwner(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "data2", "Org1MSP")
+	mustSucceed(t, s.RegisterOrganization(stub.as("Org1MSP"), "Org3MSP", "Org 3"))
+
+	mustFailWith(t, s.TransferOwnership(stub.as("Org1MSP"), "data1", "  ", ""), ErrInvalidArgument)
+
+	// Whitespace is trimmed
+	mustSucceed(t, s.TransferOwnership(stub.as("Org1MSP"), "data1", " Org2MSP\n", ""))
+	data, err := s.ReadSupplyChainData(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+	if data.OrganizationID != "Org2MSP" {
+		t.Fatalf("expected owner Org2MSP, got %q", data.OrganizationID)
+	}
+
+	// A registered organization keeps its registered spelling
+	mustSucceed(t, s.TransferOwnership(stub.as("Org1MSP"), "data2", "org3msp", ""))
+	data, err = s.ReadSupplyChainData(stub.as("Org3MSP"), "data2")
+	mustSucceed(t, err)
+	if data.OrganizationID != "Org3MSP" {
+		t.Fatalf("expected owner Org3MSP, got %q", data.OrganizationID)
+	}
+
+	// An unregistered organization keeps the spelling it has on the access control list
+	createTestData(t, stub, "data3", "Org1MSP", "Org4MSP")
+	mustSucceed(t, s.TransferOwnership(stub.as("Org1MSP"), "data3", "org4msp", ""))
+	data, err = s.ReadSupplyChainData(stub.as("Org4MSP"), "data3")
+	mustSucceed(t, err)
+	if caseInsensitiveOrgIDs && data.OrganizationID != "Org4MSP" {
+		t.Fatalf("expected owner Org4MSP, got %q", data.OrganizationID)
+	}
+
+	// Otherwise the new owner finds the data whichever way the caller spelled it
+	createTestData(t, stub, "data4", "Org1MSP")
+	mustSucceed(t, s.TransferOwnership(stub.as("Org1MSP"), "data4", "org5msp", ""))
+	if caseInsensitiveOrgIDs {
+		results, err := s.QuerySupplyChainDataByOrg(stub.as("Org5MSP"), "Org5MSP")
+		mustSucceed(t, err)
+		if len(results) != 1 || results[0].ID != "data4" {
+			t.Fatalf("expected the new owner to find data4, got %d results", len(results))
+		}
+	}
+}
+
+// endorsingOrgs returns the organizations whose peers must endorse changes to a key
+func endorsingOrgs(t *testing.T, stub *testStub, key string) []string {
+	t.Helper()
+	policy, err := stub.GetStateValidationParameter(key)
+	mustSucceed(t, err)
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	mustSucceed(t, err)
+	return endorsementPolicy.ListOrgs()
+}
+
+func TestCrossOrgModificationIsRejected(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+
+	// Only the owner's peers can endorse changes to the key
+	if orgs := endorsingOrgs(t, stub, "data1"); !reflect.DeepEqual(orgs, []string{"Org1MSP"}) {
+		t.Fatalf("expected only Org1MSP to endorse changes, got %v", orgs)
+	}
+
+	// A grantee cannot make owner-only changes either
+	hash := testDataHash("tampered")
+	mustFailWith(t, s.UpdateEncryptedData(stub.as("Org2MSP"), "data1", "tampered", hash), ErrUnauthorized)
+	mustFailWith(t, s.PatchSupplyChainData(stub.as("Org2MSP"), "data1", `{"encryptedData":"tampered","dataHash":"`+hash+`"}`), ErrUnauthorized)
+	mustFailWith(t, s.UpdateAccessControl(stub.as("Org2MSP"), "data1", []string{"Org2MSP", "Org3MSP"}), ErrUnauthorized)
+	mustFailWith(t, s.TransferOwnership(stub.as("Org2MSP"), "data1", "Org2MSP", ""), ErrUnauthorized)
+	mustFailWith(t, s.DeleteSupplyChainData(stub.as("Org2MSP"), "data1"), ErrUnauthorized)
+
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if data.EncryptedData != "payload-data1" || data.OrganizationID != "Org1MSP" || len(data.AccessControl) != 1 {
+		t.Fatalf("a grantee modified the data: %+v", data)
+	}
+
+	// After a transfer, the new owner's peers endorse changes instead
+	mustSucceed(t, s.TransferOwnership(stub.as("Org1MSP"), "data1", " Org2MSP ", ""))
+	if orgs := endorsingOrgs(t, stub, "data1"); !reflect.DeepEqual(orgs, []string{"Org2MSP"}) {
+		t.Fatalf("expected only Org2MSP to endorse changes after the transfer, got %v", orgs)
+	}
+}
+
+// simulatePeer endorses the same creates a peer would for one client proposal each and returns the resulting ledger
+func simulatePeer(t *testing.T) *testStub {
+	s := new(SmartContract)
+	stub := newTestStub()
+	mustSucceed(t, s.CreateAccessPolicy(stub.as("Org1MSP"), "policy1", "Org1MSP", []string{DataTypeShipment}, []string{"Org3MSP"}))
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+	mustSucceed(t, s.CreateSupplyChainDataSimple(stub.as("Org1MSP"), "data2", `{"dataType":"inventory"}`))
+	return stub
+}
+
+func TestWritesAreDeterministicAcrossPeers(t *testing.T) {
+	peer1 := simulatePeer(t)
+	time.Sleep(10 * time.Millisecond) // The peers' clocks differ, their transaction timestamps do not
+	peer2 := simulatePeer(t)
+
+	if !reflect.DeepEqual(peer1.State, peer2.State) {
+		for key, value := range peer1.State {
+			if !bytes.Equal(value, peer2.State[key]) {
+				t.Errorf("peers wrote different values for %q:\n%s\n%s", key, value, peer2.State[key])
+			}
+		}
+		t.Fatal("two peers endorsing the same transactions wrote different state")
+	}
+
+	// The timestamps come from the transaction, not the peer's clock
+	data, err := new(SmartContract).ReadSupplyChainData(peer1.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if want := peer1.history["data1"][0].Timestamp.AsTime(); !data.Timestamp.Equal(want) || !data.LastModified.Equal(want) {
+		t.Fatalf("expected timestamps %v, got %v and %v", want, data.Timestamp, data.LastModified)
+	}
+}
+
+func TestCanAccessMixedCase(t *testing.T) {
+	data := &SupplyChainData{OrganizationID: "Org1MSP", AccessControl: []string{"Org2MSP", "ORG3MSP"}}
+
+	tests := []struct {
+		clientOrgID string
+		want        bool
+	}{
+		{"Org1MSP", true},
+		{"org1msp", caseInsensitiveOrgIDs},
+		{"Org2MSP", true},
+		{"oRG2msp", caseInsensitiveOrgIDs},
+		{"Org3MSP", caseInsensitiveOrgIDs},
+		{"Org4MSP", false},
+		{"Org1MSPX", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := canAccess(test.clientOrgID, data); got != test.want {
+			t.Errorf("canAccess(%q) = %v, want %v", test.clientOrgID, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeOrgIDsMixedCase(t *testing.T) {
+	got := normalizeOrgIDs([]string{" Org2MSP", "org2msp ", "Org3MSP", "ORG3MSP", "Org2MSP"})
+
+	want := []string{"Org2MSP", "org2msp", "Org3MSP", "ORG3MSP"}
+	if caseInsensitiveOrgIDs {
+		// The first spelling of each organization is kept
+		want = []string{"Org2MSP", "Org3MSP"}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeOrgIDs = %q, want %q", got, want)
+	}
+}
+
+func TestOrgIDConditionMixedCase(t *testing.T) {
+	// Check the condition against the stored MSP IDs the way CouchDB would evaluate it
+	matches := func(condition map[string]string, stored string) bool {
+		if pattern, ok := condition["$regex"]; ok {
+			return regexp.MustCompile(pattern).MatchString(stored)
+		}
+		return condition["$eq"] == stored
+	}
+
+	tests := []struct {
+		orgID  string
+		stored string
+		want   bool
+	}{
+		{"Org1MSP", "Org1MSP", true},
+		{"Org1MSP", "org1msp", caseInsensitiveOrgIDs},
+		{"org1msp", "ORG1MSP", caseInsensitiveOrgIDs},
+		{"Org1MSP", "Org1MSPX", false},
+		{"Org1MSP", "XOrg1MSP", false},
+		{"Org.MSP", "OrgXMSP", false}, // Regular expression metacharacters are matched literally
+		{"Org.MSP", "org.msp", caseInsensitiveOrgIDs},
+	}
+	for _, test := range tests {
+		if got := matches(orgIDCondition(test.orgID), test.stored); got != test.want {
+			t.Errorf("orgIDCondition(%q) matching %q = %v, want %v", test.orgID, test.stored, got, test.want)
+		}
+	}
+}
+
+func TestMixedCaseAccessList(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP", " Org2MSP ", strings.ToLower("Org2MSP"))
+
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	if caseInsensitiveOrgIDs && !reflect.DeepEqual(data.AccessControl, []string{"Org2MSP"}) {
+		t.Errorf("expected the access list to be normalized at write time, got %q", data.AccessControl)
+	}
+
+	// The grantee can read the data with the MSP ID from its certificate
+	_, err = s.ReadSupplyChainData(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+}
+
+func TestCreateRejectsEmptyOrgIDs(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	encryptedData := "payload-data1"
+
+	for _, accessControl := range [][]string{{""}, {"Org2MSP", "  "}} {
+		_, err := s.CreateSupplyChainData(stub.as("Org1MSP"), "data1", "Org1MSP", encryptedData, testDataHash(encryptedData), DataTypeShipment, accessControl, "")
+		mustFailWith(t, err, ErrInvalidArgument)
+	}
+	mustFailWith(t, s.SetDefaultAccessList(stub.as("Org1MSP"), "Org1MSP", []string{"Org2MSP", ""}), ErrInvalidArgument)
+
+	// Nothing was written
+	createTestData(t, stub, "data1", "Org1MSP")
+}
+
+func TestMixedCaseAccessRequestsAndRevocations(t *testing.T) {
+	if !caseInsensitiveOrgIDs {
+		t.Skip("org IDs are case-sensitive")
+	}
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP", "Org4MSP")
+
+	// A request filed with one spelling of the MSP ID is the same request under any other
+	mustSucceed(t, s.RequestDataAccess(stub.as("org3msp"), "data1"))
+	mustFailWith(t, s.RequestDataAccess(stub.as("ORG3MSP"), "data1"), ErrAlreadyExists)
+	mustSucceed(t, s.GrantDataAccess(stub.as("Org1MSP"), "data1", "Org3MSP"))
+	_, err := s.ReadSupplyChainData(stub.as("Org3MSP"), "data1")
+	mustSucceed(t, err)
+
+	// The same holds for pending revocations
+	mustSucceed(t, s.RevokeDataAccess(stub.as("Org1MSP"), "data1", "org4msp"))
+	mustSucceed(t, s.ApproveRevocation(stub.as("Org2MSP"), "data1", "Org4MSP"))
+	_, err = s.ReadSupplyChainData(stub.as("Org4MSP"), "data1")
+	mustFailWith(t, err, ErrUnauthorized)
+}
+
+func TestMixedCaseOwnerQueries(t *testing.T) {
+	if !caseInsensitiveOrgIDs {
+		t.Skip("org IDs are case-sensitive")
+	}
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	// The same organization stores data under two spellings of its MSP ID
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "data2", "org1msp")
+
+	results, err := s.QuerySupplyChainDataByOrg(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if len(results) != 2 {
+		t.Errorf("expected QuerySupplyChainDataByOrg to return both spellings, got %d results", len(results))
+	}
+	count, err := s.CountSupplyChainDataByOrg(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if count != 2 {
+		t.Errorf("expected CountSupplyChainDataByOrg to count both spellings, got %d", count)
+	}
+	page, err := s.QuerySupplyChainDataByOrgPaginated(stub.as("ORG1MSP"), "ORG1MSP", 10, "")
+	mustSucceed(t, err)
+	if page.FetchedRecordsCount != 2 {
+		t.Errorf("expected QuerySupplyChainDataByOrgPaginated to return both spellings, got %d results", page.FetchedRecordsCount)
+	}
+
+	// Access policies created under one spelling apply to data created under another
+	mustSucceed(t, s.CreateAccessPolicy(stub.as("org1msp"), "policy1", "org1msp", []string{DataTypeShipment}, []string{"Org5MSP"}))
+	policies, err := s.QueryAccessPoliciesByOrg(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if len(policies) != 1 {
+		t.Errorf("expected QueryAccessPoliciesByOrg to return the policy, got %d policies", len(policies))
+	}
+	createTestData(t, stub, "data3", "Org1MSP")
+	_, err = s.ReadSupplyChainData(stub.as("Org5MSP"), "data3")
+	mustSucceed(t, err)
+}
+
+func TestGetAllSupplyChainDataCap(t *testing.T) {
+	defer func(max int) { maxGetAllResults = max }(maxGetAllResults)
+	maxGetAllResults = 2
+
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "data2", "Org1MSP")
+
+	results, err := s.GetAllSupplyChainData(stub.as("Org1MSP"))
+	mustSucceed(t, err)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results at the cap, got %d", len(results))
+	}
+
+	// Archived data does not count toward the cap unless it is returned
+	createTestData(t, stub, "data3", "Org1MSP")
+	mustSucceed(t, s.ArchiveSupplyChainData(stub.as("Org1MSP"), "data3"))
+	_, err = s.GetAllSupplyChainData(stub.as("Org1MSP"))
+	mustSucceed(t, err)
+	_, err = s.GetAllSupplyChainDataIncludingArchived(stub.as("Org1MSP"))
+	mustFailWith(t, err, ErrResultTooLarge)
+
+	// The scan stops as soon as the cap is exceeded
+	for _, id := range []string{"data4", "data5", "data6"} {
+		createTestData(t, stub, id, "Org1MSP")
+	}
+	stub.rangeReads = 0
+	_, err = s.GetAllSupplyChainData(stub.as("Org1MSP"))
+	mustFailWith(t, err, ErrResultTooLarge)
+	if all := len(stub.State); stub.rangeReads >= all {
+		t.Errorf("expected the scan to stop early, read %d of %d entries", stub.rangeReads, all)
+	}
+}
+
+func TestUpdateAnomalyStatusBatchRejectsDuplicateIDs(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "data2", "Org1MSP")
+
+	_, err := s.UpdateAnomalyStatusBatch(stub.as("Org1MSP"), `[
+		{"id":"data1","anomalyDetected":true,"anomalyScore":0.9,"explanation":"first","expectedVersion":1},
+		{"id":"data2","anomalyDetected":true,"anomalyScore":0.9,"explanation":"other","expectedVersion":1},
+		{"id":"data1","anomalyDetected":true,"anomalyScore":0.95,"explanation":"second","expectedVersion":2}
+	]`)
+	mustFailWith(t, err, ErrInvalidArgument)
+
+	// Nothing was applied
+	for _, id := range []string{"data1", "data2"} {
+		data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), id)
+		mustSucceed(t, err)
+		if data.AnomalyDetected {
+			t.Errorf("%s was updated by a rejected batch", id)
+		}
+	}
+}
+
+func TestUpdateAnomalyStatusBatchCoalescesEvents(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "data2", "Org1MSP")
+	createTestData(t, stub, "data3", "Org1MSP")
+	updatesJSON := `[
+		{"id":"data1","anomalyDetected":true,"anomalyScore":0.8,"explanation":"first","expectedVersion":1},
+		{"id":"data2","anomalyDetected":true,"anomalyScore":0.95,"explanation":"second","expectedVersion":1},
+		{"id":"data3","anomalyDetected":false,"anomalyScore":0.1,"explanation":"","expectedVersion":1},
+		{"id":"missing","anomalyDetected":true,"anomalyScore":0.9,"explanation":"","expectedVersion":1}
+	]`
+
+	// Only the organization itself can enable coalescing
+	mustFailWith(t, s.SetEventCoalescing(stub.as("Org2MSP"), "Org1MSP", true), ErrUnauthorized)
+	mustSucceed(t, s.SetEventCoalescing(stub.as("Org1MSP"), "org1msp", true))
+
+	stub.events = nil
+	result, err := s.UpdateAnomalyStatusBatch(stub.as("Org1MSP"), updatesJSON)
+	mustSucceed(t, err)
+	if result.Updated != 3 {
+		t.Fatalf("expected 3 updates, got %d", result.Updated)
+	}
+	if !reflect.DeepEqual(stub.events, []string{"AnomalyBatchUpdated"}) {
+		t.Fatalf("expected a single summary event, got %q", stub.events)
+	}
+	var summary AnomalyBatchUpdatedEvent
+	mustSucceed(t, json.Unmarshal(stub.lastEventPayload, &summary))
+	want := AnomalyBatchUpdatedEvent{
+		EventHeader:    EventHeader{EventVersion: anomalyBatchUpdatedEventVersion},
+		OrganizationID: "Org1MSP",
+		IDs:            []string{"data1", "data2", "data3"},
+		SeverityCounts: map[string]int{SeverityHigh: 1, SeverityCritical: 1},
+		Failed:         1,
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Fatalf("unexpected summary event: %+v", summary)
+	}
+
+	// Without coalescing, an event is set per detected anomaly
+	mustSucceed(t, s.SetEventCoalescing(stub.as("Org1MSP"), "Org1MSP", false))
+	stub.events = nil
+	_, err = s.UpdateAnomalyStatusBatch(stub.as("Org1MSP"), `[
+		{"id":"data1","anomalyDetected":true,"anomalyScore":0.95,"explanation":"again","expectedVersion":2},
+		{"id":"data2","anomalyDetected":true,"anomalyScore":0.99,"explanation":"again","expectedVersion":2}
+	]`)
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(stub.events, []string{"AnomalyDetected", "AnomalyDetected"}) {
+		t.Fatalf("expected an event per detected anomaly, got %q", stub.events)
+	}
+}
+
+func TestWriteReceipt(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+	createTestData(t, stub, "data1", "Org1MSP", "Org2MSP")
+	createTx := stub.TxID
+
+	// The receipt names the transaction that made the write, not the one asking for the receipt
+	receipt, err := s.GetWriteReceipt(stub.as("Org2MSP"), "data1")
+	mustSucceed(t, err)
+	if receipt.TxID != createTx {
+		t.Fatalf("expected the receipt of %s, got %s", createTx, receipt.TxID)
+	}
+	receiptJSON, err := json.Marshal(receipt)
+	mustSucceed(t, err)
+
+	verified, err := s.VerifyReceipt(stub.as("Org2MSP"), "data1", string(receiptJSON))
+	mustSucceed(t, err)
+	if !verified {
+		t.Fatal("the receipt of a committed write did not verify")
+	}
+
+	// The write stays verifiable after the data changes again
+	mustSucceed(t, s.AddTag(stub.as("Org1MSP"), "data1", "audited"))
+	verified, err = s.VerifyReceipt(stub.as("Org2MSP"), "data1", string(receiptJSON))
+	mustSucceed(t, err)
+	if !verified {
+		t.Fatal("the receipt stopped verifying after a later write")
+	}
+
+	// A receipt with any field altered does not verify
+	alterations := map[string]func(*WriteReceipt){
+		"version":     func(r *WriteReceipt) { r.Version++ },
+		"dataHash":    func(r *WriteReceipt) { r.DataHash = testDataHash("forged") },
+		"txId":        func(r *WriteReceipt) { r.TxID = "forged" },
+		"txTimestamp": func(r *WriteReceipt) { r.TxTimestamp = r.TxTimestamp.Add(time.Second) },
+	}
+	for field, alter := range alterations {
+		altered := *receipt
+		alter(&altered)
+		alteredJSON, err := json.Marshal(altered)
+		mustSucceed(t, err)
+
+		verified, err = s.VerifyReceipt(stub.as("Org2MSP"), "data1", string(alteredJSON))
+		mustSucceed(t, err)
+		if verified {
+			t.Errorf("a receipt with an altered %s verified", field)
+		}
+	}
+}
+
+func TestRequestReprocessing(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "archived1", "Org1MSP")
+	mustSucceed(t, s.ArchiveSupplyChainData(stub.as("Org1MSP"), "archived1"))
+	createExpiringTestData(t, stub, "expired1", "Org1MSP")
+	createTestData(t, stub, "other1", "Org2MSP")
+
+	ids, err := s.RequestReprocessing(stub.as("Org1MSP"), "Org1MSP", DataTypeShipment)
+	mustSucceed(t, err)
+	if !reflect.DeepEqual(ids, []string{"data1"}) {
+		t.Errorf("expected only the current data of the organization, got %v", ids)
+	}
+
+	// A data type cannot smuggle extra conditions into the query
+	_, err = s.RequestReprocessing(stub.as("Org1MSP"), "Org1MSP", `shipment","organizationId":"Org2MSP`)
+	mustFailWith(t, err, ErrInvalidArgument)
+}
+
+func TestGenerateTamperReport(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "parent1", "Org1MSP")
+	createTestData(t, stub, "data1", "Org1MSP")
+	for _, id := range []string{"child1", "archived1"} {
+		mustSucceed(t, s.CreateSupplyChainDataWithProvenance(stub.as("Org1MSP"), id, "Org1MSP", "payload-"+id, testDataHash("payload-"+id), DataTypeShipment, nil, []string{"parent1"}, false))
+	}
+	mustSucceed(t, s.ArchiveSupplyChainData(stub.as("Org1MSP"), "archived1"))
+	createExpiringTestData(t, stub, "expired1", "Org1MSP")
+
+	report, err := s.GenerateTamperReport(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if !report.Passed || report.RecordCount != 3 {
+		t.Fatalf("expected a clean report over the 3 current data points, got %+v", report)
+	}
+
+	// Delete a parent and tamper with an owner behind the contract's back
+	mustSucceed(t, s.DeleteSupplyChainData(stub.as("Org1MSP"), "parent1"))
+	data, err := s.ReadSupplyChainData(stub.as("Org1MSP"), "data1")
+	mustSucceed(t, err)
+	data.OwnershipHistory = []OwnershipRecord{{OrganizationID: "Org2MSP", AcquiredAt: data.Timestamp}}
+	dataJSON, err := json.Marshal(data)
+	mustSucceed(t, err)
+	stub.as("Org1MSP")
+	mustSucceed(t, stub.PutState("data1", dataJSON))
+
+	report, err = s.GenerateTamperReport(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if report.Passed || report.OwnershipConsistency.Passed || report.DanglingReferences.Passed {
+		t.Fatalf("expected the ownership and reference checks to fail, got %+v", report)
+	}
+	if !reflect.DeepEqual(report.OwnershipConsistency.OffendingIDs, []string{"data1"}) {
+		t.Errorf("expected data1 to have an ownership mismatch, got %v", report.OwnershipConsistency.OffendingIDs)
+	}
+	// The archived child is not reported
+	if !reflect.DeepEqual(report.DanglingReferences.OffendingIDs, []string{"child1"}) {
+		t.Errorf("expected child1 to have a dangling reference, got %v", report.DanglingReferences.OffendingIDs)
+	}
+}
+
+func TestQuerySupplyChainDataByOrgPaginatedExcludesArchived(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	for _, id := range []string{"data1", "data2", "data3", "data4"} {
+		createTestData(t, stub, id, "Org1MSP")
+	}
+	mustSucceed(t, s.ArchiveSupplyChainData(stub.as("Org1MSP"), "data2"))
+	createExpiringTestData(t, stub, "data5", "Org1MSP")
+
+	ids := []string{}
+	bookmark := ""
+	for {
+		page, err := s.QuerySupplyChainDataByOrgPaginated(stub.as("Org1MSP"), "Org1MSP", 2, bookmark)
+		mustSucceed(t, err)
+		if int(page.FetchedRecordsCount) != len(page.Records) {
+			t.Errorf("expected the count of the records returned, got %d for %d records", page.FetchedRecordsCount, len(page.Records))
+		}
+		for _, data := range page.Records {
+			ids = append(ids, data.ID)
+		}
+		if page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+	if !reflect.DeepEqual(ids, []string{"data1", "data3", "data4"}) {
+		t.Errorf("expected the current data across all pages, got %v", ids)
+	}
+}
+
+func TestCountSupplyChainDataByOrgMatchesQuery(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	createTestData(t, stub, "data1", "Org1MSP")
+	createTestData(t, stub, "archived1", "Org1MSP")
+	mustSucceed(t, s.ArchiveSupplyChainData(stub.as("Org1MSP"), "archived1"))
+	createExpiringTestData(t, stub, "expired1", "Org1MSP")
+	createTestData(t, stub, "other1", "Org2MSP")
+
+	count, err := s.CountSupplyChainDataByOrg(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	results, err := s.QuerySupplyChainDataByOrg(stub.as("Org1MSP"), "Org1MSP")
+	mustSucceed(t, err)
+	if count != 1 || len(results) != count {
+		t.Errorf("expected a count of 1 matching the query, got %d for %d records", count, len(results))
+	}
+}
+
+func TestUpdateAccessPolicyValidatesLikeCreate(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	mustSucceed(t, s.CreateAccessPolicy(stub.as("Org1MSP"), "policy1", "Org1MSP", []string{DataTypeShipment}, []string{"Org2MSP"}))
+
+	invalid := []struct {
+		dataTypes   []string
+		allowedOrgs []string
+	}{
+		{[]string{"not-a-type"}, []string{"Org2MSP"}},
+		{[]string{DataTypeShipment}, []string{"Org2MSP", " "}},
+	}
+	for _, test := range invalid {
+		mustFailWith(t, s.CreateAccessPolicy(stub.as("Org1MSP"), "policy2", "Org1MSP", test.dataTypes, test.allowedOrgs), ErrInvalidArgument)
+		mustFailWith(t, s.UpdateAccessPolicy(stub.as("Org1MSP"), "policy1", test.dataTypes, test.allowedOrgs), ErrInvalidArgument)
+	}
+
+	mustSucceed(t, s.UpdateAccessPolicy(stub.as("Org1MSP"), "policy1", []string{DataTypeShipment, DataTypeShipment}, []string{" Org3MSP ", "org3msp"}))
+	policy, err := s.ReadAccessPolicy(stub.as("Org1MSP"), "policy1")
+	mustSucceed(t, err)
+	wantOrgs := []string{"Org3MSP", "org3msp"}
+	if caseInsensitiveOrgIDs {
+		wantOrgs = []string{"Org3MSP"}
+	}
+	if !reflect.DeepEqual(policy.DataTypes, []string{DataTypeShipment}) || !reflect.DeepEqual(policy.AllowedOrgs, wantOrgs) {
+		t.Errorf("expected the update to be normalized, got %+v", policy)
+	}
+}
+
+func TestTimeRangeQueriesWithSubSecondTimestamps(t *testing.T) {
+	s := new(SmartContract)
+	stub := newTestStub()
+
+	// data1 is created at 00:00:01.5 and data2 at 00:00:02
+	createTestDataAt(t, stub, "data1", "Org1MSP", 500*time.Millisecond)
+	createTestDataAt(t, stub, "data2", "Org1MSP", 0)
+	at := func(offset time.Duration) string {
+		return testEpoch.Add(offset).Format(time.RFC3339Nano)
+	}
+	ids := func(results []*SupplyChainData, err error) []string {
+		t.Helper()
+		mustSucceed(t, err)
+		found := []string{}
+		for _, result := range results {
+			found = append(found, result.ID)
+		}
+		return found
+	}
+
+	tests := []struct {
+		name    string
+		results []string
+		want    []string
+	}{
+		{"time range within the second", ids(s.QuerySupplyChainDataByTimeRange(stub.as("Org1MSP"), "Org1MSP", at(time.Second), at(1900*time.Millisecond))), []string{"data1"}},
+		{"time range after the fraction", ids(s.QuerySupplyChainDataByTimeRange(stub.as("Org1MSP"), "Org1MSP", at(1600*time.Millisecond), at(2*time.Second))), []string{"data2"}},
+		{"time range ending on the second", ids(s.QuerySupplyChainDataByTimeRange(stub.as("Org1MSP"), "Org1MSP", at(0), at(time.Second))), []string{}},
+		{"modified since the second", ids(s.GetModifiedSince(stub.as("Org1MSP"), "Org1MSP", at(time.Second))), []string{"data1", "data2"}},
+		{"modified since the fraction", ids(s.GetModifiedSince(stub.as("Org1MSP"), "Org1MSP", at(1500*time.Millisecond))), []string{"data2"}},
+		{"filter within the second", ids(s.QuerySupplyChainData(stub.as("Org1MSP"), `{"startTime":"`+at(time.Second)+`","endTime":"`+at(1900*time.Millisecond)+`"}`)), []string{"data1"}},
+		{"filter from the fraction", ids(s.QuerySupplyChainData(stub.as("Org1MSP"), `{"startTime":"`+at(1500*time.Millisecond)+`"}`)), []string{"data1", "data2"}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.results, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, test.results, test.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxTagLength is the maximum number of characters of a tag
+const maxTagLength = 64
+
+// CreateSupplyChainDataWithTags adds a new supply chain data point labeled with free-form tags, e.g. "cold-chain",
+// that can later be used to find it with QueryByTag
+func (s *SmartContract) CreateSupplyChainDataWithTags(ctx contractapi.TransactionContextInterface, id, organizationID, encryptedData, dataHash, dataType string, accessControl, tags []string) error {
+	normalizedTags := []string{}
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		if !contains(normalizedTags, tag) {
+			normalizedTags = append(normalizedTags, tag)
+		}
+	}
+
+	// Validate and build the supply chain data object
+	supplyChainData, err := s.newSupplyChainData(ctx, id, organizationID, encryptedData, dataHash, dataType, accessControl)
+	if err != nil {
+		return err
+	}
+	supplyChainData.Tags = normalizedTags
+
+	// Put the data and its index entries on the ledger
+	return putNewSupplyChainData(ctx, supplyChainData)
+}
+
+// AddTag labels a supply chain data point with a tag. Only the owning organization can tag its data.
+func (s *SmartContract) AddTag(ctx contractapi.TransactionContextInterface, id, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if contains(supplyChainData.Tags, tag) {
+		return fmt.Errorf("%w: the supply chain data %s is already tagged %q", ErrAlreadyExists, id, tag)
+	}
+	supplyChainData.Tags = append(supplyChainData.Tags, tag)
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	return writeAuditEntry(ctx, "AddTag", id)
+}
+
+// RemoveTag removes a tag from a supply chain data point. Only the owning organization can untag its data.
+func (s *SmartContract) RemoveTag(ctx contractapi.TransactionContextInterface, id, tag string) error {
+	tag = strings.TrimSpace(tag)
+
+	// Get the supply chain data, verifying the client owns it
+	supplyChainData, err := getOwnedSupplyChainData(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotDisputed(supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	if !contains(supplyChainData.Tags, tag) {
+		return fmt.Errorf("%w: the supply chain data %s is not tagged %q", ErrNotFound, id, tag)
+	}
+
+	remaining := []string{}
+	for _, existing := range supplyChainData.Tags {
+		if existing != tag {
+			remaining = append(remaining, existing)
+		}
+	}
+	supplyChainData.Tags = remaining
+
+	// Put the data back on the ledger
+	err = putSupplyChainData(ctx, supplyChainData)
+	if err != nil {
+		return err
+	}
+
+	return writeAuditEntry(ctx, "RemoveTag", id)
+}
+
+// QueryByTag returns the supply chain data labeled with a tag that the client can access, excluding archived data
+func (s *SmartContract) QueryByTag(ctx contractapi.TransactionContextInterface, tag string) ([]*SupplyChainData, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the identity of the client submitting the transaction
+	clientOrgID, err := getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the ledger for all data with the tag
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"tags": map[string]interface{}{"$elemMatch": map[string]string{"$eq": tag}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer resultIterator.Close()
+
+	results, err := constructQueryResponseFromIterator(resultIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter the results for access control
+	return excludeExpired(ctx, excludeArchived(filterByAccess(clientOrgID, results)))
+}
+
+// normalizeTag trims a tag and checks it is neither empty nor too long
+func normalizeTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", fmt.Errorf("%w: tag must not be empty", ErrInvalidArgument)
+	}
+	if len([]rune(tag)) > maxTagLength {
+		return "", fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidArgument, tag, maxTagLength)
+	}
+	return tag, nil
+}
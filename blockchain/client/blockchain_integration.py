@@ -45,7 +45,7 @@ class BlockchainIntegration:
         if not self.client:
             logger.error("Failed to create Fabric client. Blockchain integration will not work.")
     
-    def store_data(self, data_id, organization_id, encrypted_data, data_hash, data_type, access_control=None):
+    def store_data(self, data_id, organization_id, encrypted_data, data_hash, data_type, access_control=None, idempotency_key=None):
         """
         Store encrypted supply chain data in the blockchain.
         
@@ -56,6 +56,9 @@ class BlockchainIntegration:
             data_hash (str): The hash of the original data for integrity verification.
             data_type (str): The type of supply chain data (e.g., shipment, inventory, production).
             access_control (list, optional): List of organizations that can access this data.
+            idempotency_key (str, optional): A key identifying this create; retrying with the
+                same key and data_id returns the ID of the data already stored instead of storing it
+                again. Keys are scoped to the organization and cannot be reused for another data_id.
             
         Returns:
             dict: The transaction result.
@@ -87,7 +90,7 @@ class BlockchainIntegration:
                 self.channel_name,
                 self.chaincode_name,
                 'CreateSupplyChainData',
-                [data_id, organization_id, encrypted_data, data_hash, data_type, access_control_str]
+                [data_id, organization_id, encrypted_data, data_hash, data_type, access_control_str, idempotency_key or '']
             )
             
             return result
@@ -99,7 +102,8 @@ class BlockchainIntegration:
                 'message': str(e)
             }
     
-    def update_anomaly_status(self, data_id, anomaly_detected, anomaly_score, explanation):
+    def update_anomaly_status(self, data_id, anomaly_detected, anomaly_score, explanation, expected_version,
+                              anomaly_metadata=None):
         """
         Update the anomaly status of a supply chain data point.
         
@@ -108,6 +112,10 @@ class BlockchainIntegration:
             anomaly_detected (bool): Whether an anomaly was detected.
             anomaly_score (float): The anomaly score.
             explanation (str): The explanation of the anomaly.
+            expected_version (int): The version of the data last read; the update is
+                rejected with a CONFLICT error if the data has changed since.
+            anomaly_metadata (dict, optional): Structured output of the detection model,
+                such as feature attributions. It is stored on the ledger as a JSON document.
             
         Returns:
             dict: The transaction result.
@@ -124,12 +132,16 @@ class BlockchainIntegration:
             if isinstance(explanation, dict):
                 explanation = json.dumps(explanation)
             
+            # The chaincode takes the model output as a JSON document, or an empty string for none
+            anomaly_metadata_json = json.dumps(anomaly_metadata) if anomaly_metadata is not None else ''
+            
             # Submit transaction to update anomaly status
             result = self.client.submit_transaction(
                 self.channel_name,
                 self.chaincode_name,
                 'UpdateAnomalyStatus',
-                [data_id, str(anomaly_detected).lower(), str(anomaly_score), explanation]
+                [data_id, str(anomaly_detected).lower(), str(anomaly_score), explanation, anomaly_metadata_json,
+                 str(expected_version)]
             )
             
             return result
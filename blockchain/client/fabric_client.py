@@ -35,6 +35,7 @@ class FabricClient:
         
         # Mock storage for development/testing
         self.mock_ledger = {}
+        self.mock_idempotency_keys = {}
         
         logger.info(f"Initialized Fabric client for organization {self.org_id}")
     
@@ -88,6 +89,21 @@ class FabricClient:
             # Store in mock ledger for development/testing
             if function_name == 'CreateSupplyChainData':
                 data_id = args[0]  # First arg is the ID
+                # Idempotency keys are scoped to the organization, like on the ledger
+                idempotency_key = (args[1], args[6]) if len(args) > 6 and args[6] else None
+                if idempotency_key and idempotency_key in self.mock_idempotency_keys:
+                    existing_id = self.mock_idempotency_keys[idempotency_key]
+                    if existing_id != data_id:
+                        raise Exception(f"CONFLICT: idempotency key {args[6]!r} was already used to create "
+                                        f"the supply chain data {existing_id}")
+                    return {
+                        'success': True,
+                        'transaction_id': tx_id,
+                        'timestamp': datetime.now().isoformat(),
+                        'result': existing_id
+                    }
+                if idempotency_key:
+                    self.mock_idempotency_keys[idempotency_key] = data_id
                 self.mock_ledger[data_id] = {
                     'id': data_id,
                     'organizationId': args[1],
@@ -98,14 +114,25 @@ class FabricClient:
                     'timestamp': datetime.now().isoformat(),
                     'anomalyDetected': False,
                     'anomalyScore': 0.0,
-                    'explanation': ""
+                    'explanation': "",
+                    'version': 1
                 }
             elif function_name == 'UpdateAnomalyStatus':
                 data_id = args[0]  # First arg is the ID
                 if data_id in self.mock_ledger:
-                    self.mock_ledger[data_id]['anomalyDetected'] = args[1] == 'true' if isinstance(args[1], str) else args[1]
+                    if len(args) > 5 and int(args[5]) != self.mock_ledger[data_id]['version']:
+                        raise Exception(f"CONFLICT: the supply chain data {data_id} is at version "
+                                        f"{self.mock_ledger[data_id]['version']}, expected version {args[5]}")
+                    anomaly_detected = args[1] == 'true' if isinstance(args[1], str) else args[1]
+                    if self.mock_ledger[data_id]['anomalyDetected'] and not anomaly_detected:
+                        raise Exception(f"CONFLICT: the supply chain data {data_id} has a detected anomaly, "
+                                        f"which can only be cleared through ProposeAnomalyResolution")
+                    self.mock_ledger[data_id]['version'] += 1
+                    self.mock_ledger[data_id]['anomalyDetected'] = anomaly_detected
                     self.mock_ledger[data_id]['anomalyScore'] = float(args[2])
                     self.mock_ledger[data_id]['explanation'] = args[3]
+                    if len(args) > 4 and args[4]:
+                        self.mock_ledger[data_id]['anomalyMetadata'] = args[4]
             
             return {
                 'success': True,